@@ -0,0 +1,101 @@
+package redis
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// argRule is the accepted number of arguments of a command, not counting the
+// command name. max < 0 means no upper bound.
+type argRule struct {
+	min, max int
+}
+
+// argRules only lists common commands, anything else is passed through
+// untouched and left for the server to check.
+var argRules = map[string]argRule{
+	"GET":      {1, 1},
+	"SET":      {2, -1},
+	"SETNX":    {2, 2},
+	"SETEX":    {3, 3},
+	"GETSET":   {2, 2},
+	"MGET":     {1, -1},
+	"DEL":      {1, -1},
+	"UNLINK":   {1, -1},
+	"EXISTS":   {1, -1},
+	"EXPIRE":   {2, 3},
+	"PEXPIRE":  {2, 3},
+	"TTL":      {1, 1},
+	"PTTL":     {1, 1},
+	"INCR":     {1, 1},
+	"DECR":     {1, 1},
+	"INCRBY":   {2, 2},
+	"DECRBY":   {2, 2},
+	"HGET":     {2, 2},
+	"HSET":     {3, -1},
+	"HDEL":     {2, -1},
+	"HGETALL":  {1, 1},
+	"HINCRBY":  {3, 3},
+	"LPUSH":    {2, -1},
+	"RPUSH":    {2, -1},
+	"LRANGE":   {3, 3},
+	"LLEN":     {1, 1},
+	"SADD":     {2, -1},
+	"SREM":     {2, -1},
+	"SMEMBERS": {1, 1},
+	"SCARD":    {1, 1},
+	"ZADD":     {3, -1},
+	"ZSCORE":   {2, 2},
+	"ZCARD":    {1, 1},
+}
+
+func validateArgs(cmd string, n int) error {
+	rule, ok := argRules[strings.ToUpper(cmd)]
+	if !ok {
+		return nil
+	}
+	if n < rule.min || (rule.max >= 0 && n > rule.max) {
+		want := fmt.Sprintf("%d", rule.min)
+		if rule.max < 0 {
+			want = fmt.Sprintf("at least %d", rule.min)
+		} else if rule.max != rule.min {
+			want = fmt.Sprintf("%d to %d", rule.min, rule.max)
+		}
+		return fmt.Errorf("%w: %s wants %s arguments, got %d", ErrBadArgs, strings.ToUpper(cmd), want, n)
+	}
+	return nil
+}
+
+// scalarArg reports whether FlatCmd sends a as a single argument. Slices
+// other than []byte, arrays, maps and structs are expanded into several,
+// unless they marshal themselves.
+func scalarArg(a interface{}) bool {
+	switch a.(type) {
+	case nil, []byte, encoding.TextMarshaler, encoding.BinaryMarshaler:
+		return true
+	}
+	switch reflect.Indirect(reflect.ValueOf(a)).Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		return false
+	}
+	return true
+}
+
+// validateFlatArgs checks the arguments of Do. FlatCmd expands slices and
+// maps, those calls are not checked.
+func validateFlatArgs(cmd string, args []interface{}) error {
+	for _, a := range args {
+		if a == nil {
+			continue
+		}
+		switch reflect.TypeOf(a).Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+			if _, ok := a.([]byte); !ok {
+				return nil
+			}
+		}
+	}
+	return validateArgs(cmd, len(args)+1)
+}
@@ -0,0 +1,107 @@
+package redis
+
+import (
+	"container/heap"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+type BigKey struct {
+	Key   string
+	Type  string
+	Bytes int64
+}
+
+// bigKeyHeap is a min-heap on Bytes, the smallest of the current top is
+// evicted first.
+type bigKeyHeap []BigKey
+
+func (h bigKeyHeap) Len() int            { return len(h) }
+func (h bigKeyHeap) Less(i, j int) bool  { return h[i].Bytes < h[j].Bytes }
+func (h bigKeyHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *bigKeyHeap) Push(x interface{}) { *h = append(*h, x.(BigKey)) }
+func (h *bigKeyHeap) Pop() interface{} {
+	old := *h
+	k := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return k
+}
+
+// bigKeySamples is passed to MEMORY USAGE SAMPLES, nested values beyond it
+// are estimated
+var bigKeySamples = "5"
+
+// FindBigKeys returns the topN keys matching sampleMatch (all keys when empty)
+// using the most memory, largest first. Keys are walked with SCAN and every
+// page is sized with one pipeline of MEMORY USAGE and TYPE on the node it came
+// from, so the server is never blocked, but the whole keyspace is visited.
+func FindBigKeys(tag string, sampleMatch string, topN int) ([]BigKey, error) {
+	return FindBigKeysContext(context.Background(), tag, sampleMatch, topN)
+}
+
+// FindBigKeysContext is FindBigKeys that stops with ctx.Err() once ctx is
+// done.
+func FindBigKeysContext(ctx context.Context, tag string, sampleMatch string, topN int) ([]BigKey, error) {
+	t := time.Now()
+	if topN <= 0 {
+		return []BigKey{}, nil
+	}
+
+	it, err := ScanKeys(tag, sampleMatch, 100)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &bigKeyHeap{}
+	scanned := 0
+	for it.node < len(it.nodes) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := it.scan(); err != nil {
+			return nil, err
+		}
+		page, n := it.buf, it.nodes[it.pageNode]
+		it.buf = nil
+		if len(page) == 0 {
+			continue
+		}
+		scanned += len(page)
+
+		sizes := make([]radix.MaybeNil, len(page))
+		keys := make([]BigKey, len(page))
+		cmds := make([]radix.CmdAction, 0, 2*len(page))
+		for i, key := range page {
+			keys[i].Key = key
+			sizes[i].Rcv = &keys[i].Bytes
+			cmds = append(cmds,
+				radix.Cmd(&sizes[i], "MEMORY", "USAGE", key, "SAMPLES", bigKeySamples),
+				radix.Cmd(&keys[i].Type, "TYPE", key))
+		}
+		if err := doChecked(tag, n.client, radix.Pipeline(cmds...), "MEMORY", "TYPE"); err != nil {
+			return nil, wrapErr(tag, "MEMORY USAGE", err)
+		}
+
+		for i, k := range keys {
+			if sizes[i].Nil {
+				// deleted since SCAN returned it
+				continue
+			}
+			if h.Len() < topN {
+				heap.Push(h, k)
+			} else if k.Bytes > (*h)[0].Bytes {
+				(*h)[0] = k
+				heap.Fix(h, 0)
+			}
+		}
+	}
+
+	keys := []BigKey(*h)
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Bytes > keys[j].Bytes })
+
+	logInfo("redis.FindBigKeys cost:%v tag:%s match:%s scanned:%d", time.Since(t), tag, sampleMatch, scanned)
+	return keys, nil
+}
@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFindBigKeysCancelled(t *testing.T) {
+	s, tag := newTestTag(t)
+	s.set("big:k", "v")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := FindBigKeysContext(ctx, tag, "", 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestFindBigKeys(t *testing.T) {
+	addr := realRedis(t)
+	tag := t.Name()
+	if err := InitRedisStandalone([]StandaloneConfig{{Tag: tag, Addr: addr}}); err != nil {
+		t.Fatal(err)
+	}
+	defer Close(tag)
+
+	var ok string
+	if err := Do(&ok, tag, "SET", "bigkeys:small", "v"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Do(&ok, tag, "SET", "bigkeys:large", strings.Repeat("v", 4096)); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	defer Do(&n, tag, "DEL", "bigkeys:small", "bigkeys:large")
+
+	keys, err := FindBigKeys(tag, "bigkeys:*", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].Key != "bigkeys:large" || keys[0].Type != "string" {
+		t.Fatalf("got %+v, want bigkeys:large as a string", keys)
+	}
+}
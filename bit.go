@@ -0,0 +1,87 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BitFieldOp is one operation of BitField, built with BitFieldGet,
+// BitFieldSet, BitFieldIncrBy or BitFieldOverflow. Type is a BITFIELD type
+// like "u8" or "i5", Offset is in bits.
+type BitFieldOp struct {
+	Op       string
+	Type     string
+	Offset   int64
+	Value    int64
+	Overflow string
+}
+
+func BitFieldGet(typ string, offset int64) BitFieldOp {
+	return BitFieldOp{Op: "GET", Type: typ, Offset: offset}
+}
+
+func BitFieldSet(typ string, offset, value int64) BitFieldOp {
+	return BitFieldOp{Op: "SET", Type: typ, Offset: offset, Value: value}
+}
+
+func BitFieldIncrBy(typ string, offset, incr int64) BitFieldOp {
+	return BitFieldOp{Op: "INCRBY", Type: typ, Offset: offset, Value: incr}
+}
+
+// BitFieldOverflow sets how the following SET and INCRBY ops overflow: WRAP,
+// SAT or FAIL.
+func BitFieldOverflow(mode string) BitFieldOp {
+	return BitFieldOp{Op: "OVERFLOW", Overflow: mode}
+}
+
+func (o BitFieldOp) args() ([]string, error) {
+	offset := strconv.FormatInt(o.Offset, 10)
+	switch strings.ToUpper(o.Op) {
+	case "GET":
+		return []string{"GET", o.Type, offset}, nil
+	case "SET":
+		return []string{"SET", o.Type, offset, strconv.FormatInt(o.Value, 10)}, nil
+	case "INCRBY":
+		return []string{"INCRBY", o.Type, offset, strconv.FormatInt(o.Value, 10)}, nil
+	case "OVERFLOW":
+		mode := strings.ToUpper(o.Overflow)
+		switch mode {
+		case "WRAP", "SAT", "FAIL":
+			return []string{"OVERFLOW", mode}, nil
+		}
+		return nil, fmt.Errorf("%w: BITFIELD OVERFLOW [%s], want WRAP, SAT or FAIL", ErrBadArgs, o.Overflow)
+	}
+	return nil, fmt.Errorf("%w: BITFIELD op [%s]", ErrBadArgs, o.Op)
+}
+
+// BitField runs ops on key in one BITFIELD and returns one value per op that
+// is not OVERFLOW, in order: the value read for GET, the old value for SET
+// and the new value for INCRBY. An op refused by OVERFLOW FAIL gives 0.
+func BitField(tag, key string, ops ...BitFieldOp) ([]int64, error) {
+	args := []string{key}
+	for _, op := range ops {
+		opArgs, err := op.args()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, opArgs...)
+	}
+
+	var reply []interface{}
+	if err := DoCmd(&reply, tag, "BITFIELD", args...); err != nil {
+		return nil, err
+	}
+	ret := make([]int64, len(reply))
+	for i, v := range reply {
+		if v == nil {
+			continue
+		}
+		n, err := replyInt(v)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = n
+	}
+	return ret, nil
+}
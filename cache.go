@@ -0,0 +1,220 @@
+package redis
+
+import (
+	"container/list"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// commands that never change a key, they don't evict it from the client cache
+var cacheReadCmds = map[string]bool{
+	"GET":      true,
+	"MGET":     true,
+	"STRLEN":   true,
+	"GETRANGE": true,
+	"EXISTS":   true,
+	"TYPE":     true,
+	"TTL":      true,
+	"PTTL":     true,
+}
+
+// commands emptying the whole database, they clear the cache of the tag
+var cacheFlushCmds = map[string]bool{
+	"FLUSHDB":  true,
+	"FLUSHALL": true,
+}
+
+// invalidations bump the generation of the stripe of their key, a fill only
+// stores its value when the generation didn't change while it read from Redis
+const cacheStripes = 64
+
+type cacheEntry struct {
+	key    string
+	value  []byte
+	expire time.Time
+}
+
+// lruCache holds values of hot keys in process, evicting the least recently
+// used entry once size is reached.
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[string]*list.Element
+	gens  [cacheStripes]uint64
+}
+
+var clientCacheMu sync.RWMutex
+var clientCache *lruCache
+
+// EnableClientCache puts an LRU cache of size entries in front of GetString
+// and GetBytes, entries live for ttl. Commands sent through this package
+// evict the keys they touch, writes from other processes are only seen once
+// the entry expired: CLIENT TRACKING invalidation needs RESP3, which the
+// radix v3 client doesn't speak. A size or ttl <= 0 disables the cache.
+func EnableClientCache(size int, ttl time.Duration) {
+	var c *lruCache
+	if size > 0 && ttl > 0 {
+		c = &lruCache{
+			size:  size,
+			ttl:   ttl,
+			ll:    list.New(),
+			items: make(map[string]*list.Element, size),
+		}
+	}
+
+	clientCacheMu.Lock()
+	clientCache = c
+	clientCacheMu.Unlock()
+	logInfo("redis.EnableClientCache size:%d ttl:%v", size, ttl)
+}
+
+func getClientCache() *lruCache {
+	clientCacheMu.RLock()
+	defer clientCacheMu.RUnlock()
+	return clientCache
+}
+
+func cacheKey(tag, key string) string {
+	return tag + "\x00" + key
+}
+
+func cacheStripe(k string) int {
+	h := fnv.New32a()
+	h.Write([]byte(k))
+	return int(h.Sum32() % cacheStripes)
+}
+
+func copyBytes(b []byte) []byte {
+	return append([]byte{}, b...)
+}
+
+// generation is read before a fill, see set.
+func (c *lruCache) generation(k string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gens[cacheStripe(k)]
+}
+
+func (c *lruCache) get(k string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[k]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*cacheEntry)
+	if time.Now().After(entry.expire) {
+		c.ll.Remove(e)
+		delete(c.items, k)
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return copyBytes(entry.value), true
+}
+
+// set stores a copy of value unless k was invalidated since gen was read, the
+// value may then predate the write that invalidated it.
+func (c *lruCache) set(k string, value []byte, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.gens[cacheStripe(k)] != gen {
+		return
+	}
+	entry := &cacheEntry{key: k, value: copyBytes(value), expire: time.Now().Add(c.ttl)}
+	if e, ok := c.items[k]; ok {
+		e.Value = entry
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.items[k] = c.ll.PushFront(entry)
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *lruCache) remove(k string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gens[cacheStripe(k)]++
+	if e, ok := c.items[k]; ok {
+		c.ll.Remove(e)
+		delete(c.items, k)
+	}
+}
+
+// clear evicts all entries of tag.
+func (c *lruCache) clear(tag string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.gens {
+		c.gens[i]++
+	}
+	prefix := cacheKey(tag, "")
+	for k, e := range c.items {
+		if strings.HasPrefix(k, prefix) {
+			c.ll.Remove(e)
+			delete(c.items, k)
+		}
+	}
+}
+
+// invalidateCached evicts what cmd may have written from the client cache.
+// Keys can be anywhere in the arguments (DEL k1 k2, RENAME src dst, MSET k1 v1
+// k2 v2), so every argument is evicted; evicting a value along is harmless.
+func invalidateCached(tag, cmd string, args []string) {
+	c := getClientCache()
+	if c == nil {
+		return
+	}
+	cmd = strings.ToUpper(cmd)
+	if cacheFlushCmds[cmd] {
+		c.clear(tag)
+		return
+	}
+	if cacheReadCmds[cmd] {
+		return
+	}
+	for _, arg := range args {
+		c.remove(cacheKey(tag, arg))
+	}
+}
+
+// GetBytes returns false when key doesn't exist, missing keys are not cached.
+func GetBytes(tag, key string) ([]byte, bool, error) {
+	c := getClientCache()
+	var gen uint64
+	if c != nil {
+		if value, ok := c.get(cacheKey(tag, key)); ok {
+			return value, true, nil
+		}
+		gen = c.generation(cacheKey(tag, key))
+	}
+
+	var value []byte
+	found, err := doMaybe(&value, tag, "GET", key)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	if c != nil {
+		c.set(cacheKey(tag, key), value, gen)
+	}
+	return value, true, nil
+}
+
+func GetString(tag, key string) (string, bool, error) {
+	value, ok, err := GetBytes(tag, key)
+	return string(value), ok, err
+}
@@ -0,0 +1,30 @@
+package redis
+
+import (
+	"testing"
+	"time"
+)
+
+func enableTestCache(t *testing.T) {
+	EnableClientCache(16, time.Minute)
+	t.Cleanup(func() { EnableClientCache(0, 0) })
+}
+
+func TestCacheFillRacingInvalidation(t *testing.T) {
+	enableTestCache(t)
+	c := getClientCache()
+
+	k := cacheKey("race", "k")
+	gen := c.generation(k)
+	// a write invalidates k while the fill is reading the old value
+	invalidateCached("race", "SET", []string{"k", "new"})
+	c.set(k, []byte("old"), gen)
+	if v, ok := c.get(k); ok {
+		t.Fatalf("stale fill %q stored", v)
+	}
+
+	c.set(k, []byte("new"), c.generation(k))
+	if v, ok := c.get(k); !ok || string(v) != "new" {
+		t.Fatalf("got %q %v, want new", v, ok)
+	}
+}
@@ -0,0 +1,95 @@
+package redis
+
+import (
+	"fmt"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func isCluster(tag string) bool {
+	client, err := getClientByTag(tag)
+	if err != nil {
+		return false
+	}
+	_, ok := client.(*radix.Cluster)
+	return ok
+}
+
+// checkSameSlot returns ErrCrossSlot when tag is a cluster and keys don't all
+// hash to the same slot, so multi-key commands fail before the round trip
+// with a clear error instead of the server's CROSSSLOT.
+func checkSameSlot(tag string, keys ...string) error {
+	if len(keys) < 2 || !isCluster(tag) {
+		return nil
+	}
+	slot := radix.ClusterSlot([]byte(keys[0]))
+	for _, k := range keys[1:] {
+		if radix.ClusterSlot([]byte(k)) != slot {
+			return fmt.Errorf("%w: [%s] and [%s]", ErrCrossSlot, keys[0], k)
+		}
+	}
+	return nil
+}
+
+// splitBySlot groups keys by cluster slot, keeping the order of first
+// appearance. Non-cluster tags get a single group with all keys.
+func splitBySlot(tag string, keys []string) [][]string {
+	if !isCluster(tag) {
+		return [][]string{keys}
+	}
+
+	var groups [][]string
+	index := map[uint16]int{}
+	for _, k := range keys {
+		slot := radix.ClusterSlot([]byte(k))
+		i, ok := index[slot]
+		if !ok {
+			i = len(groups)
+			index[slot] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], k)
+	}
+	return groups
+}
+
+// pipelineBySlot sends cmd(i), a command named name, for every key, one
+// pipeline per slot on cluster and a single one otherwise.
+func pipelineBySlot(tag, name string, keys []string, cmd func(i int) radix.CmdAction) error {
+	client, err := getClientByTag(tag)
+	if err != nil {
+		return err
+	}
+
+	var groups [][]int
+	if isCluster(tag) {
+		index := map[uint16]int{}
+		for i, k := range keys {
+			slot := radix.ClusterSlot([]byte(k))
+			g, ok := index[slot]
+			if !ok {
+				g = len(groups)
+				index[slot] = g
+				groups = append(groups, nil)
+			}
+			groups[g] = append(groups[g], i)
+		}
+	} else {
+		all := make([]int, len(keys))
+		for i := range keys {
+			all[i] = i
+		}
+		groups = [][]int{all}
+	}
+
+	for _, group := range groups {
+		cmds := make([]radix.CmdAction, len(group))
+		for j, i := range group {
+			cmds[j] = cmd(i)
+		}
+		if err := doChecked(tag, client, radix.Pipeline(cmds...), name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
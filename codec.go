@@ -0,0 +1,92 @@
+package redis
+
+import (
+	"bufio"
+	"encoding"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/mediocregopher/radix/v3/resp"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// Codec turns values into the bytes stored in Redis and back, e.g. gob,
+// protobuf or JSON.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type defaultCodec struct {
+	codec Codec
+	types map[reflect.Type]bool
+}
+
+// holds a *defaultCodec, nil when none is set
+var rcvCodec atomic.Value
+
+// SetDefaultCodec makes Do, DoCmd and their Context variants decode replies
+// with codec for receivers of the registered types, given as a value or a
+// pointer of each type. Once it is set the receiver is decoded by the first
+// that applies:
+//
+//	resp.Unmarshaler (radix.MaybeNil, ...) - radix, as before
+//	pointer to a registered type            - codec.Unmarshal of the reply
+//	encoding.BinaryUnmarshaler             - UnmarshalBinary of the reply
+//	anything else                           - radix's default decoding
+//
+// A nil reply leaves the receiver untouched. A nil codec turns this off.
+func SetDefaultCodec(codec Codec, types ...interface{}) {
+	var dc *defaultCodec
+	if codec != nil {
+		dc = &defaultCodec{codec: codec, types: make(map[reflect.Type]bool, len(types))}
+		for _, v := range types {
+			t := reflect.TypeOf(v)
+			if t != nil && t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			dc.types[t] = true
+		}
+	}
+	rcvCodec.Store(dc)
+}
+
+type codecRcv struct {
+	unmarshal func(data []byte) error
+}
+
+func (c *codecRcv) UnmarshalRESP(br *bufio.Reader) error {
+	var raw resp2.RawMessage
+	if err := raw.UnmarshalRESP(br); err != nil {
+		return err
+	}
+	if raw.IsNil() {
+		return nil
+	}
+	var b []byte
+	if err := raw.UnmarshalInto(&resp2.Any{I: &b}); err != nil {
+		return err
+	}
+	return c.unmarshal(b)
+}
+
+// codecReceiver wraps rcv to be decoded by the default codec, see
+// SetDefaultCodec, or returns it as is.
+func codecReceiver(rcv interface{}) interface{} {
+	dc, _ := rcvCodec.Load().(*defaultCodec)
+	if dc == nil || rcv == nil {
+		return rcv
+	}
+	if _, ok := rcv.(resp.Unmarshaler); ok {
+		return rcv
+	}
+	if t := reflect.TypeOf(rcv); t.Kind() == reflect.Ptr && dc.types[t.Elem()] {
+		return &codecRcv{unmarshal: func(data []byte) error {
+			return dc.codec.Unmarshal(data, rcv)
+		}}
+	}
+	if bu, ok := rcv.(encoding.BinaryUnmarshaler); ok {
+		return &codecRcv{unmarshal: bu.UnmarshalBinary}
+	}
+	return rcv
+}
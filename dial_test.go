@@ -0,0 +1,333 @@
+package redis
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testCert returns a server config with a self-signed certificate for
+// 127.0.0.1 and the path of that certificate as a PEM file, to be used as CA.
+func testCert(t *testing.T) (*tls.Config, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fakeredis"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, caFile
+}
+
+// socks5Server is a minimal SOCKS5 proxy, CONNECT only, with
+// username/password auth when user is set. It counts the connections it
+// relayed.
+type socks5Server struct {
+	ln         net.Listener
+	user, pass string
+	relayed    int32
+}
+
+func newSocks5Server(t *testing.T, user, pass string) *socks5Server {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &socks5Server{ln: ln, user: user, pass: pass}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(c)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return p
+}
+
+func (p *socks5Server) addr() string {
+	return p.ln.Addr().String()
+}
+
+func (p *socks5Server) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	read := func(n int) []byte {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil
+		}
+		return b
+	}
+
+	// VER NMETHODS METHODS
+	hdr := read(2)
+	if hdr == nil || read(int(hdr[1])) == nil {
+		return
+	}
+	if len(p.user) == 0 {
+		c.Write([]byte{5, 0})
+	} else {
+		c.Write([]byte{5, 2})
+		// VER ULEN UNAME PLEN PASSWD
+		ulen := read(2)
+		if ulen == nil {
+			return
+		}
+		user := read(int(ulen[1]))
+		plen := read(1)
+		if plen == nil {
+			return
+		}
+		pass := read(int(plen[0]))
+		if string(user) != p.user || string(pass) != p.pass {
+			c.Write([]byte{1, 1})
+			return
+		}
+		c.Write([]byte{1, 0})
+	}
+
+	// VER CMD RSV ATYP DST.ADDR DST.PORT
+	req := read(4)
+	if req == nil {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		host = net.IP(read(4)).String()
+	case 3:
+		l := read(1)
+		if l == nil {
+			return
+		}
+		host = string(read(int(l[0])))
+	default:
+		return
+	}
+	port := read(2)
+	if port == nil {
+		return
+	}
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+	if err != nil {
+		c.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	atomic.AddInt32(&p.relayed, 1)
+	c.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go func() {
+		io.Copy(target, r)
+		target.Close()
+	}()
+	io.Copy(c, target)
+}
+
+func TestDialCombinations(t *testing.T) {
+	for _, useTLS := range []bool{false, true} {
+		for _, useSocks5 := range []bool{false, true} {
+			for _, auth := range []bool{false, true} {
+				for _, sel := range []bool{false, true} {
+					name := fmt.Sprintf("tls=%v/socks5=%v/auth=%v/select=%v", useTLS, useSocks5, auth, sel)
+					t.Run(name, func(t *testing.T) {
+						testDial(t, useTLS, useSocks5, auth, sel)
+					})
+				}
+			}
+		}
+	}
+}
+
+func testDial(t *testing.T, useTLS, useSocks5, auth, sel bool) {
+	var serverCfg *tls.Config
+	c := StandaloneConfig{Tag: t.Name(), PoolSize: 2}
+	if useTLS {
+		var caFile string
+		serverCfg, caFile = testCert(t)
+		c.TLS = TLSConfig{Enabled: true, CAFile: caFile}
+	}
+	s := newFakeRedis(t, serverCfg)
+	c.Addr = s.addr()
+
+	var proxy *socks5Server
+	if useSocks5 {
+		proxy = newSocks5Server(t, "u", "p")
+		c.Socks5 = Socks5ProxyConfig{User: "u", Pass: "p", Addr: proxy.addr()}
+	}
+	if auth {
+		s.requireAuth("app", "secret")
+		c.Username, c.Password = "app", "secret"
+	}
+	if sel {
+		c.DB = 2
+	}
+
+	if err := InitRedisStandalone([]StandaloneConfig{c}); err != nil {
+		t.Fatal(err)
+	}
+	defer Close(c.Tag)
+
+	var ok string
+	if err := Do(&ok, c.Tag, "SET", "dial:k", "v"); err != nil {
+		t.Fatal(err)
+	}
+	db := 0
+	if sel {
+		db = 2
+	}
+	if v, ok := s.get(db, "dial:k"); !ok || v != "v" {
+		t.Fatalf("db %d has %q %v, want v", db, v, ok)
+	}
+	if proxy != nil && atomic.LoadInt32(&proxy.relayed) == 0 {
+		t.Fatal("no connection went through the proxy")
+	}
+}
+
+func TestDialAuthRejected(t *testing.T) {
+	s := newFakeRedis(t, nil)
+	s.requireAuth("", "secret")
+
+	err := InitRedisStandalone([]StandaloneConfig{{Tag: t.Name(), Addr: s.addr(), Password: "wrong"}})
+	if err == nil {
+		Close(t.Name())
+		t.Fatal("init with a wrong password succeeded")
+	}
+	if ClassifyError(err) != KindAuth {
+		t.Fatalf("got %v, want an auth error", err)
+	}
+}
+
+// silentServer accepts connections and never answers.
+func silentServer(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, c)
+			mu.Unlock()
+			go io.Copy(ioutil.Discard, c)
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, c := range conns {
+			c.Close()
+		}
+	})
+	return ln.Addr().String()
+}
+
+// within fails the test when fn hasn't returned after d.
+func within(t *testing.T, d time.Duration, fn func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+	case <-time.After(d):
+		t.Fatalf("still blocked after %v", d)
+	}
+}
+
+func TestDialSocks5Timeouts(t *testing.T) {
+	t.Run("proxy", func(t *testing.T) {
+		c := StandaloneConfig{Tag: t.Name(), Addr: "127.0.0.1:6379", ConnectTimeout: 100}
+		c.Socks5 = Socks5ProxyConfig{Addr: silentServer(t)}
+		within(t, 2*time.Second, func() {
+			if err := InitRedisStandalone([]StandaloneConfig{c}); err == nil {
+				Close(c.Tag)
+				t.Error("init through a stalled proxy succeeded")
+			}
+		})
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		_, caFile := testCert(t)
+		c := StandaloneConfig{Tag: t.Name(), Addr: silentServer(t), ConnectTimeout: 100}
+		c.Socks5 = Socks5ProxyConfig{Addr: newSocks5Server(t, "", "").addr()}
+		c.TLS = TLSConfig{Enabled: true, CAFile: caFile}
+		within(t, 2*time.Second, func() {
+			if err := InitRedisStandalone([]StandaloneConfig{c}); err == nil {
+				Close(c.Tag)
+				t.Error("init with a stalled TLS handshake succeeded")
+			}
+		})
+	})
+
+	t.Run("read", func(t *testing.T) {
+		c := StandaloneConfig{Tag: t.Name(), Addr: silentServer(t), PoolSize: 1, Timeout: 100}
+		c.Socks5 = Socks5ProxyConfig{Addr: newSocks5Server(t, "", "").addr()}
+		var err error
+		within(t, 2*time.Second, func() {
+			err = InitRedisStandalone([]StandaloneConfig{c})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer Close(c.Tag)
+
+		within(t, 2*time.Second, func() {
+			var ok string
+			if err := Do(&ok, c.Tag, "SET", "dial:k", "v"); !isTimeoutErr(err) {
+				t.Errorf("got %v, want a timeout", err)
+			}
+		})
+	})
+}
@@ -0,0 +1,195 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+var ErrTagNotFound = errors.New("Can not find client with tag")
+var ErrClientType = errors.New("Client Type err!")
+var ErrBadArgs = errors.New("Bad arguments")
+var ErrPoolExhausted = radix.ErrPoolEmpty
+var ErrCrossSlot = errors.New("Keys in request don't hash to the same slot")
+var ErrModuleMissing = errors.New("Module not loaded")
+var ErrFlushDisabled = errors.New("FLUSHDB is disabled, see SetAllowFlush")
+var ErrDebugDisabled = errors.New("DEBUG is disabled, see SetAllowDebug")
+var ErrCommandDenied = errors.New("Command denied by policy")
+var ErrEmptyValue = errors.New("Empty value")
+var ErrKeyNotFound = errors.New("Key not found")
+var ErrUnsupportedVersion = errors.New("Command not supported by server version")
+var ErrNotInteger = errors.New("Value is not an integer or out of range")
+var ErrNotFloat = errors.New("Value is not a valid float")
+var ErrTooManyKeys = errors.New("Operation would affect too many keys")
+
+// RedisError is returned when a command failed, either with an error reply
+// or on the connection. Use errors.As to get at the tag and command, the
+// underlying radix error is available through errors.Unwrap.
+type RedisError struct {
+	Tag string
+	Cmd string
+	Err error
+}
+
+func (e *RedisError) Error() string {
+	return fmt.Sprintf("redis %s on tag [%s]: %v", e.Cmd, e.Tag, e.Err)
+}
+
+func (e *RedisError) Unwrap() error {
+	return e.Err
+}
+
+// NodeErrors is returned by commands fanned out to every primary, keyed by
+// node address, only the failed nodes are listed.
+type NodeErrors map[string]error
+
+func (e NodeErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for addr, err := range e {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", addr, err))
+	}
+	sort.Strings(msgs)
+	return "Failed on nodes: " + strings.Join(msgs, "; ")
+}
+
+func wrapErr(tag, cmd string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RedisError{Tag: tag, Cmd: cmd, Err: err}
+}
+
+// isReplyErr reports whether err is an error reply of the server, as opposed
+// to a failure of the connection.
+func isReplyErr(err error) bool {
+	var e resp2.Error
+	return errors.As(err, &e)
+}
+
+// isUnknownCommandErr reports whether the server rejected the command itself,
+// usually because it is older than the command.
+func isUnknownCommandErr(err error) bool {
+	var e resp2.Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	return strings.HasPrefix(e.Error(), "ERR unknown command")
+}
+
+// isSyntaxErr reports whether the server rejected an option, usually because
+// it is older than the option.
+func isSyntaxErr(err error) bool {
+	var e resp2.Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	return strings.HasPrefix(e.Error(), "ERR syntax error")
+}
+
+// numberErr turns the replies of INCR-like commands on a key holding
+// something that isn't a number into ErrNotInteger or ErrNotFloat.
+func numberErr(err error, key string) error {
+	var e resp2.Error
+	if !errors.As(err, &e) {
+		return err
+	}
+	msg := e.Error()
+	switch {
+	case strings.HasPrefix(msg, "ERR value is not an integer"), strings.HasPrefix(msg, "ERR hash value is not an integer"):
+		return fmt.Errorf("%w [%s]", ErrNotInteger, key)
+	case strings.HasPrefix(msg, "ERR value is not a valid float"), strings.HasPrefix(msg, "ERR hash value is not a float"):
+		return fmt.Errorf("%w [%s]", ErrNotFloat, key)
+	}
+	return err
+}
+
+func isTimeoutErr(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+type ErrorKind int
+
+const (
+	KindNone ErrorKind = iota
+	KindGeneric
+	KindWrongType
+	KindNoScript
+	KindMoved
+	KindAsk
+	KindOOM
+	KindConn
+	KindAuth
+	KindBusy
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindNone:
+		return "none"
+	case KindWrongType:
+		return "wrongtype"
+	case KindNoScript:
+		return "noscript"
+	case KindMoved:
+		return "moved"
+	case KindAsk:
+		return "ask"
+	case KindOOM:
+		return "oom"
+	case KindConn:
+		return "conn"
+	case KindAuth:
+		return "auth"
+	case KindBusy:
+		return "busy"
+	}
+	return "generic"
+}
+
+// ClassifyError tells apart the failures callers usually react to: error
+// replies are classified by their prefix (WRONGTYPE, NOSCRIPT, MOVED, ASK,
+// OOM, NOAUTH/WRONGPASS, BUSY), network failures are KindConn and anything else
+// is KindGeneric.
+func ClassifyError(err error) ErrorKind {
+	if err == nil {
+		return KindNone
+	}
+
+	var e resp2.Error
+	if errors.As(err, &e) {
+		prefix := e.Error()
+		if i := strings.IndexByte(prefix, ' '); i >= 0 {
+			prefix = prefix[:i]
+		}
+		switch prefix {
+		case "WRONGTYPE":
+			return KindWrongType
+		case "NOSCRIPT":
+			return KindNoScript
+		case "MOVED":
+			return KindMoved
+		case "ASK":
+			return KindAsk
+		case "OOM":
+			return KindOOM
+		case "NOAUTH", "WRONGPASS":
+			return KindAuth
+		case "BUSY":
+			return KindBusy
+		}
+		return KindGeneric
+	}
+
+	var ne net.Error
+	if errors.As(err, &ne) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return KindConn
+	}
+	return KindGeneric
+}
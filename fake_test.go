@@ -0,0 +1,283 @@
+package redis
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is a minimal Redis server for the tests of this package, which
+// can't depend on miniredis, see testutil for the tests needing more. It
+// knows PING, AUTH, SELECT, GET, SET, DEL, BLPOP and CLUSTER SLOTS, as a
+// single node owning all slots. Anything else gets an unknown command error.
+type fakeRedis struct {
+	ln     net.Listener
+	closed chan struct{}
+	wg     sync.WaitGroup
+
+	mu         sync.Mutex
+	user, pass string
+	dbs        map[int]map[string]string
+	conns      map[*fakeConn]struct{}
+}
+
+type fakeConn struct {
+	net.Conn
+
+	// guarded by fakeRedis.mu
+	authed bool
+	db     int
+}
+
+// newFakeRedis starts a fakeRedis, serving TLS with cfg when set. It is
+// closed when the test ends.
+func newFakeRedis(t testing.TB, cfg *tls.Config) *fakeRedis {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg != nil {
+		ln = tls.NewListener(ln, cfg)
+	}
+	s := &fakeRedis{
+		ln:     ln,
+		closed: make(chan struct{}),
+		dbs:    map[int]map[string]string{},
+		conns:  map[*fakeConn]struct{}{},
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			fc := &fakeConn{Conn: c}
+			s.mu.Lock()
+			select {
+			case <-s.closed:
+				c.Close()
+			default:
+				s.conns[fc] = struct{}{}
+				s.wg.Add(1)
+				go s.serve(fc)
+			}
+			s.mu.Unlock()
+		}
+	}()
+	t.Cleanup(s.close)
+	return s
+}
+
+func (s *fakeRedis) addr() string {
+	return s.ln.Addr().String()
+}
+
+// close stops the server and waits for all its goroutines.
+func (s *fakeRedis) close() {
+	s.mu.Lock()
+	select {
+	case <-s.closed:
+		s.mu.Unlock()
+		return
+	default:
+	}
+	close(s.closed)
+	s.ln.Close()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// requireAuth makes every connection that didn't AUTH with user and pass
+// get NOAUTH, an empty user stands for the default one.
+func (s *fakeRedis) requireAuth(user, pass string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user, s.pass = user, pass
+}
+
+func (s *fakeRedis) set(k, v string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.db(0)[k] = v
+}
+
+func (s *fakeRedis) get(db int, k string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.db(db)[k]
+	return v, ok
+}
+
+// connCount returns the number of open client connections.
+func (s *fakeRedis) connCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// db must be called with mu held.
+func (s *fakeRedis) db(n int) map[string]string {
+	if s.dbs[n] == nil {
+		s.dbs[n] = map[string]string{}
+	}
+	return s.dbs[n]
+}
+
+func (s *fakeRedis) serve(c *fakeConn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+
+	r := bufio.NewReader(c)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if _, err := io.WriteString(c, s.exec(c, args)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeRedis) exec(c *fakeConn, args []string) string {
+	name := strings.ToUpper(args[0])
+	if name == "AUTH" {
+		return s.auth(c, args[1:])
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pass) > 0 && !c.authed {
+		return "-NOAUTH Authentication required.\r\n"
+	}
+
+	switch {
+	case name == "PING":
+		return "+PONG\r\n"
+	case name == "SELECT" && len(args) == 2:
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return "-ERR value is not an integer or out of range\r\n"
+		}
+		c.db = n
+		return "+OK\r\n"
+	case name == "GET" && len(args) == 2:
+		v, ok := s.db(c.db)[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return respBulk(v)
+	case name == "SET" && len(args) >= 3:
+		s.db(c.db)[args[1]] = args[2]
+		return "+OK\r\n"
+	case name == "DEL" && len(args) >= 2:
+		n := 0
+		for _, k := range args[1:] {
+			if _, ok := s.db(c.db)[k]; ok {
+				delete(s.db(c.db), k)
+				n++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	case name == "BLPOP" && len(args) >= 3:
+		// nothing is ever pushed, block for the timeout
+		secs, err := strconv.ParseFloat(args[len(args)-1], 64)
+		if err != nil {
+			return "-ERR timeout is not a float or out of range\r\n"
+		}
+		s.mu.Unlock()
+		select {
+		case <-time.After(time.Duration(secs * float64(time.Second))):
+		case <-s.closed:
+		}
+		s.mu.Lock()
+		return "*-1\r\n"
+	case name == "CLUSTER" && len(args) == 2 && strings.ToUpper(args[1]) == "SLOTS":
+		host, port, _ := net.SplitHostPort(s.addr())
+		return "*1\r\n*3\r\n:0\r\n:16383\r\n*2\r\n" + respBulk(host) + ":" + port + "\r\n"
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+}
+
+func (s *fakeRedis) auth(c *fakeConn, args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, pass := "", ""
+	switch len(args) {
+	case 1:
+		pass = args[0]
+	case 2:
+		user, pass = args[0], args[1]
+		if user == "default" {
+			user = ""
+		}
+	default:
+		return "-ERR wrong number of arguments for 'auth' command\r\n"
+	}
+	if len(s.pass) == 0 {
+		return "-ERR AUTH called without any password configured for the default user\r\n"
+	}
+	if user != s.user || pass != s.pass {
+		return "-WRONGPASS invalid username-password pair or user is disabled.\r\n"
+	}
+	c.authed = true
+	return "+OK\r\n"
+}
+
+// readCommand reads one command sent as an array of bulk strings, which is
+// how radix sends all of them.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	n, err := readHeader(r, '*')
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		l, err := readHeader(r, '$')
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, l+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:l])
+	}
+	return args, nil
+}
+
+func readHeader(r *bufio.Reader, prefix byte) (int, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	if len(line) < 3 || line[0] != prefix {
+		return 0, fmt.Errorf("got %q, want a %c header", line, prefix)
+	}
+	return strconv.Atoi(strings.TrimSuffix(line[1:], "\r\n"))
+}
+
+func respBulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
@@ -0,0 +1,173 @@
+package redis
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// HLen returns 0 for a missing key, like LLen, SCard and ZCard.
+func HLen(tag, key string) (int64, error) {
+	var n int64
+	err := Do(&n, tag, "HLEN", key)
+	return n, err
+}
+
+// HGet returns false when key or field doesn't exist.
+func HGet(tag, key, field string) (string, bool, error) {
+	var value string
+	ok, err := doMaybe(&value, tag, "HGET", key, field)
+	return value, ok, err
+}
+
+func HIncrBy(tag, key, field string, delta int64) (int64, error) {
+	var n int64
+	if err := Do(&n, tag, "HINCRBY", key, field, delta); err != nil {
+		return 0, numberErr(err, key)
+	}
+	return n, nil
+}
+
+// HExpire sets the TTL of fields of the hash (Redis 7.4) and returns a status
+// per field, in order: 1 set, 0 refused, 2 field deleted as ttl is 0, -2 no
+// such field. HPEXPIRE is used so sub-second durations are kept.
+func HExpire(tag, key string, ttl time.Duration, fields ...string) ([]int64, error) {
+	if len(fields) == 0 {
+		return []int64{}, nil
+	}
+	if err := requireVersion(tag, "7.4.0"); err != nil {
+		return nil, err
+	}
+
+	args := append([]string{key, strconv.FormatInt(ttl.Milliseconds(), 10), "FIELDS", strconv.Itoa(len(fields))}, fields...)
+	var ret []int64
+	if err := DoCmd(&ret, tag, "HPEXPIRE", args...); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// HTTL returns the remaining TTL of fields of the hash (Redis 7.4), in order.
+// A field without a TTL gives -1 and a missing field -2, like TTL does for
+// keys, as time.Duration(-1) and time.Duration(-2).
+func HTTL(tag, key string, fields ...string) ([]time.Duration, error) {
+	if len(fields) == 0 {
+		return []time.Duration{}, nil
+	}
+	if err := requireVersion(tag, "7.4.0"); err != nil {
+		return nil, err
+	}
+
+	args := append([]string{key, "FIELDS", strconv.Itoa(len(fields))}, fields...)
+	var reply []int64
+	if err := DoCmd(&reply, tag, "HPTTL", args...); err != nil {
+		return nil, err
+	}
+	ret := make([]time.Duration, len(reply))
+	for i, ms := range reply {
+		if ms < 0 {
+			ret[i] = time.Duration(ms)
+		} else {
+			ret[i] = time.Duration(ms) * time.Millisecond
+		}
+	}
+	return ret, nil
+}
+
+// HRandField returns count random fields of the hash, a negative count allows
+// the same field more than once. Without withValues the map values are
+// empty, and since a map can't hold a field twice repeats are collapsed.
+func HRandField(tag, key string, count int, withValues bool) (map[string]string, error) {
+	args := []interface{}{count}
+	if withValues {
+		args = append(args, "WITHVALUES")
+	}
+	var reply []string
+	if err := Do(&reply, tag, "HRANDFIELD", key, args...); err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]string, len(reply))
+	if !withValues {
+		for _, f := range reply {
+			ret[f] = ""
+		}
+		return ret, nil
+	}
+	if len(reply)%2 != 0 {
+		return nil, fmt.Errorf("Unexpected HRANDFIELD reply length %d", len(reply))
+	}
+	for i := 0; i < len(reply); i += 2 {
+		ret[reply[i]] = reply[i+1]
+	}
+	return ret, nil
+}
+
+// an HGETALL reply of a missing key
+const emptyArrayReply = "*0\r\n"
+
+// HGetAllStruct decodes a hash into the struct out points to, fields are
+// matched by their `redis` tag or else their name. It returns false when key
+// doesn't exist.
+func HGetAllStruct(tag, key string, out interface{}) (bool, error) {
+	var raw resp2.RawMessage
+	if err := Do(&raw, tag, "HGETALL", key); err != nil {
+		return false, err
+	}
+	if string(raw) == emptyArrayReply {
+		return false, nil
+	}
+	return true, wrapErr(tag, "HGETALL", raw.UnmarshalInto(&resp2.Any{I: out}))
+}
+
+// HGetAllStructMany pipelines HGETALL for keys and decodes the hashes like
+// HGetAllStruct into the slice out points to, whose elements are structs or
+// pointers to structs. Missing keys give zero-value elements, or are left
+// out when skipMissing is passed.
+func HGetAllStructMany(tag string, keys []string, out interface{}, skipMissing ...bool) error {
+	sv := reflect.ValueOf(out)
+	if sv.Kind() != reflect.Ptr || sv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("%w: HGetAllStructMany needs a pointer to a slice, got %T", ErrBadArgs, out)
+	}
+	skip := len(skipMissing) > 0 && skipMissing[0]
+
+	raws := make([]resp2.RawMessage, len(keys))
+	err := pipelineBySlot(tag, "HGETALL", keys, func(i int) radix.CmdAction {
+		return radix.Cmd(&raws[i], "HGETALL", keys[i])
+	})
+	if err != nil {
+		return wrapErr(tag, "HGETALL", err)
+	}
+
+	slice := sv.Elem()
+	elemType := slice.Type().Elem()
+	ret := reflect.MakeSlice(slice.Type(), 0, len(keys))
+	for i, raw := range raws {
+		missing := string(raw) == emptyArrayReply
+		if missing && skip {
+			continue
+		}
+
+		var elem reflect.Value
+		if elemType.Kind() == reflect.Ptr {
+			elem = reflect.New(elemType.Elem())
+		} else {
+			elem = reflect.New(elemType)
+		}
+		if !missing {
+			if err := raw.UnmarshalInto(&resp2.Any{I: elem.Interface()}); err != nil {
+				return wrapErr(tag, "HGETALL", fmt.Errorf("key [%s]: %w", keys[i], err))
+			}
+		}
+		if elemType.Kind() != reflect.Ptr {
+			elem = elem.Elem()
+		}
+		ret = reflect.Append(ret, elem)
+	}
+	slice.Set(ret)
+	return nil
+}
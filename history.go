@@ -0,0 +1,100 @@
+package redis
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+// CommandRecord is one command of the history kept by EnableCommandHistory.
+// Only the first key is recorded, never values or other arguments.
+type CommandRecord struct {
+	Time    time.Time
+	Tag     string
+	Cmd     string
+	Key     string
+	Latency time.Duration
+	Err     error
+}
+
+type commandRing struct {
+	mu   sync.Mutex
+	recs []CommandRecord
+	next int
+	full bool
+}
+
+// holds a *commandRing, nil while the history is disabled
+var commandHistory atomic.Value
+
+// EnableCommandHistory keeps the last size commands of Do, DoCmd, DoReplica,
+// DoReadOnly, Eval, EvalSmart and their Context variants in memory, see
+// CommandHistory.
+// size <= 0 disables it, which is the default.
+func EnableCommandHistory(size int) {
+	var r *commandRing
+	if size > 0 {
+		r = &commandRing{recs: make([]CommandRecord, size)}
+	}
+	commandHistory.Store(r)
+}
+
+// CommandHistory returns the recorded commands, oldest first.
+func CommandHistory() []CommandRecord {
+	r, _ := commandHistory.Load().(*commandRing)
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]CommandRecord(nil), r.recs[:r.next]...)
+	}
+	ret := make([]CommandRecord, 0, len(r.recs))
+	ret = append(ret, r.recs[r.next:]...)
+	return append(ret, r.recs[:r.next]...)
+}
+
+func recordCommand(tag, cmd, key string, latency time.Duration, err error) {
+	r, _ := commandHistory.Load().(*commandRing)
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	r.recs[r.next] = CommandRecord{
+		Time:    time.Now(),
+		Tag:     tag,
+		Cmd:     cmd,
+		Key:     key,
+		Latency: latency,
+		Err:     err,
+	}
+	if r.next++; r.next == len(r.recs) {
+		r.next, r.full = 0, true
+	}
+	r.mu.Unlock()
+}
+
+// recordCmdArgs is recordCommand for commands given as plain args, the key is
+// only worked out when the history is on.
+func recordCmdArgs(tag, cmd string, args []string, latency time.Duration, err error) {
+	if r, _ := commandHistory.Load().(*commandRing); r == nil {
+		return
+	}
+	var key string
+	if keys := radix.Cmd(nil, cmd, args...).Keys(); len(keys) > 0 {
+		key = keys[0]
+	}
+	recordCommand(tag, cmd, key, latency, err)
+}
+
+func scriptKey(numKeys int, args []string) string {
+	if numKeys > 0 && len(args) > 0 {
+		return args[0]
+	}
+	return ""
+}
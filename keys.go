@@ -0,0 +1,315 @@
+package redis
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// ExpireOpt sets the TTL of key only if flag (Redis 7: NX, XX, GT or LT)
+// allows it, e.g. GT never shortens an existing TTL. It returns whether the
+// TTL was set. PEXPIRE is used so sub-second durations are kept.
+func ExpireOpt(tag, key string, d time.Duration, flag string) (bool, error) {
+	flag = strings.ToUpper(flag)
+	switch flag {
+	case "NX", "XX", "GT", "LT":
+	default:
+		return false, fmt.Errorf("Invalid expire flag [%s], want one of NX, XX, GT, LT", flag)
+	}
+	if err := requireVersion(tag, "7.0.0"); err != nil {
+		return false, err
+	}
+
+	var n int64
+	err := Do(&n, tag, "PEXPIRE", key, d.Milliseconds(), flag)
+	return n == 1, err
+}
+
+// RandomKey returns false when the db is empty. On cluster a random primary
+// is asked on every call.
+func RandomKey(tag string) (string, bool, error) {
+	nodes, err := primaryClients(tag)
+	if err != nil {
+		return "", false, err
+	}
+	if len(nodes) == 0 {
+		return "", false, nil
+	}
+
+	n := nodes[rand.Intn(len(nodes))]
+	var key string
+	mn := radix.MaybeNil{Rcv: &key}
+	if err := doChecked(tag, n.client, radix.Cmd(&mn, "RANDOMKEY"), "RANDOMKEY"); err != nil {
+		return "", false, wrapErr(tag, "RANDOMKEY", err)
+	}
+	return key, !mn.Nil, nil
+}
+
+// SampleKeys calls RandomKey n times, pass dedup to drop repeated keys, in
+// which case fewer than n keys may be returned.
+func SampleKeys(tag string, n int, dedup ...bool) ([]string, error) {
+	var seen map[string]bool
+	if len(dedup) > 0 && dedup[0] {
+		seen = make(map[string]bool, n)
+	}
+
+	keys := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		key, ok, err := RandomKey(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if seen != nil {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Touch updates the access time of keys without reading them and returns how
+// many exist. On cluster one TOUCH is sent per slot.
+func Touch(tag string, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	var total int64
+	for _, group := range splitBySlot(tag, keys) {
+		var n int64
+		if err := DoCmd(&n, tag, "TOUCH", group...); err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// DeleteMatching unlinks every key matching match and returns how many were
+// removed. With a guard the matches are collected first, up to one over the
+// limit, and nothing is deleted when there are too many; otherwise keys are
+// deleted page by page as the scan goes.
+func DeleteMatching(tag, match string, guard SizeGuard) (deleted int64, err error) {
+	t := time.Now()
+	defer func() {
+		logInfo("redis.DeleteMatching cost:%v tag:%s match:%s deleted:%d err:%v", time.Since(t), tag, match, deleted, err)
+	}()
+
+	if guard.enabled() {
+		keys, err := Keys(tag, match, int(guard.ConfirmIfLargerThan)+1)
+		if err != nil {
+			return 0, err
+		}
+		if err := guard.check(tag, int64(len(keys))); err != nil {
+			return 0, err
+		}
+		return unlinkKeys(tag, keys)
+	}
+
+	const batchSize = 500
+	batch := make([]string, 0, batchSize)
+	err = ScanEach(tag, match, batchSize, func(key string) error {
+		if batch = append(batch, key); len(batch) < batchSize {
+			return nil
+		}
+		n, err := unlinkKeys(tag, batch)
+		deleted += n
+		batch = batch[:0]
+		return err
+	})
+	if err != nil {
+		return deleted, err
+	}
+	n, err := unlinkKeys(tag, batch)
+	return deleted + n, err
+}
+
+// unlinkKeys sends one UNLINK per slot on cluster.
+func unlinkKeys(tag string, keys []string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	var total int64
+	for _, group := range splitBySlot(tag, keys) {
+		var n int64
+		if err := DoCmd(&n, tag, "UNLINK", group...); err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// UnlinkBatch removes keys with UNLINK, which frees their memory in the
+// background, sending at most batchSize commands per pipeline and one
+// pipeline per slot on cluster. A failing key or batch doesn't stop the rest:
+// failed holds the error of every key that may not have been removed, err is
+// only set when nothing could be sent.
+func UnlinkBatch(tag string, keys []string, batchSize int) (deleted int64, failed map[string]error, err error) {
+	t := time.Now()
+	defer func() {
+		logInfo("redis.UnlinkBatch cost:%v tag:%s keys:%d deleted:%d failed:%d err:%v", time.Since(t), tag, len(keys), deleted, len(failed), err)
+	}()
+
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	client, err := getClientByTag(tag)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := checkCommand(tag, "UNLINK"); err != nil {
+		// fail once instead of for every batch
+		return 0, nil, err
+	}
+
+	failed = map[string]error{}
+	for _, group := range splitBySlot(tag, keys) {
+		for start := 0; start < len(group); start += batchSize {
+			end := start + batchSize
+			if end > len(group) {
+				end = len(group)
+			}
+			batch := group[start:end]
+
+			replies := make([]resp2.RawMessage, len(batch))
+			cmds := make([]radix.CmdAction, len(batch))
+			for i, key := range batch {
+				cmds[i] = radix.Cmd(&replies[i], "UNLINK", key)
+			}
+			if err := doChecked(tag, client, radix.Pipeline(cmds...), "UNLINK"); err != nil {
+				err = wrapErr(tag, "UNLINK", err)
+				for _, key := range batch {
+					failed[key] = err
+				}
+				continue
+			}
+			for i, raw := range replies {
+				var n int64
+				if err := raw.UnmarshalInto(&resp2.Any{I: &n}); err != nil {
+					failed[batch[i]] = wrapErr(tag, "UNLINK", err)
+					continue
+				}
+				deleted += n
+			}
+		}
+	}
+	invalidateCached(tag, "UNLINK", keys)
+	return deleted, failed, nil
+}
+
+// Copy duplicates src into dst (Redis 6.2) and returns false when dst exists
+// and replace is false. destDB selects the database of dst, -1 keeps the one
+// of the connection. Cluster has only database 0 and src and dst must be in
+// the same slot there.
+func Copy(tag, src, dst string, destDB int, replace bool) (bool, error) {
+	if isCluster(tag) && destDB > 0 {
+		return false, fmt.Errorf("%w: COPY to DB %d on cluster tag [%s]", ErrBadArgs, destDB, tag)
+	}
+	if err := checkSameSlot(tag, src, dst); err != nil {
+		return false, err
+	}
+	if err := requireVersion(tag, "6.2.0"); err != nil {
+		return false, err
+	}
+
+	args := []string{src, dst}
+	if destDB >= 0 && !isCluster(tag) {
+		args = append(args, "DB", strconv.Itoa(destDB))
+	}
+	if replace {
+		args = append(args, "REPLACE")
+	}
+	var n int64
+	err := DoCmd(&n, tag, "COPY", args...)
+	return n == 1, err
+}
+
+// ExpireTime returns the absolute time key expires at (Redis 7), false when
+// it has no expiry. A missing key gives ErrKeyNotFound.
+func ExpireTime(tag, key string) (time.Time, bool, error) {
+	if err := requireVersion(tag, "7.0.0"); err != nil {
+		return time.Time{}, false, err
+	}
+
+	var ms int64
+	if err := Do(&ms, tag, "PEXPIRETIME", key); err != nil {
+		return time.Time{}, false, err
+	}
+	switch ms {
+	case -2:
+		return time.Time{}, false, fmt.Errorf("%w [%s]", ErrKeyNotFound, key)
+	case -1:
+		return time.Time{}, false, nil
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)), true, nil
+}
+
+func ObjectRefCount(tag, key string) (int64, error) {
+	var n int64
+	err := DoCmd(&n, tag, "OBJECT", "REFCOUNT", key)
+	return n, err
+}
+
+// KeyInfo describes a key, TTL is -1 when the key has no expiry and Len is
+// the length or cardinality for the type (STRLEN for strings).
+type KeyInfo struct {
+	Type        string
+	TTL         time.Duration
+	MemoryUsage int64
+	Encoding    string
+	Len         int64
+}
+
+// keyLenScript returns the length of KEYS[1] with the command of its type,
+// 0 for types without one.
+const keyLenScript = `
+local cmds = {string='STRLEN', list='LLEN', set='SCARD', zset='ZCARD', hash='HLEN', stream='XLEN'}
+local cmd = cmds[redis.call('TYPE', KEYS[1]).ok]
+if cmd then
+	return redis.call(cmd, KEYS[1])
+end
+return 0
+`
+
+// Inspect gathers TYPE, PTTL, MEMORY USAGE, OBJECT ENCODING and the length of
+// key in one pipelined round trip, the length comes from a script picking
+// the command of the type. A missing key gives ErrKeyNotFound.
+func Inspect(tag, key string) (KeyInfo, error) {
+	var info KeyInfo
+	var pttl int64
+	p := NewPipeline(tag).
+		Add(&info.Type, "TYPE", key).
+		Add(&pttl, "PTTL", key).
+		Add(&radix.MaybeNil{Rcv: &info.MemoryUsage}, "MEMORY", "USAGE", key).
+		Add(&radix.MaybeNil{Rcv: &info.Encoding}, "OBJECT", "ENCODING", key).
+		Add(&info.Len, "EVAL", keyLenScript, "1", key)
+	if err := p.Exec(); err != nil {
+		return KeyInfo{}, err
+	}
+	if info.Type == "none" {
+		return KeyInfo{}, fmt.Errorf("%w [%s]", ErrKeyNotFound, key)
+	}
+	for _, r := range p.Results() {
+		if r.Err != nil {
+			return KeyInfo{}, r.Err
+		}
+	}
+
+	info.TTL = time.Duration(pttl) * time.Millisecond
+	if pttl < 0 {
+		info.TTL = -1
+	}
+	return info, nil
+}
@@ -0,0 +1,27 @@
+package redis
+
+import "testing"
+
+func TestInspect(t *testing.T) {
+	addr := realRedis(t)
+	tag := t.Name()
+	if err := InitRedisStandalone([]StandaloneConfig{{Tag: tag, Addr: addr}}); err != nil {
+		t.Fatal(err)
+	}
+	defer Close(tag)
+
+	var n int64
+	if err := DoCmd(&n, tag, "DEL", "inspect:list"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DoCmd(&n, tag, "RPUSH", "inspect:list", "a", "b", "c"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := Inspect(tag, "inspect:list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Type != "list" || info.Len != 3 || info.TTL != -1 || info.MemoryUsage <= 0 || len(info.Encoding) == 0 {
+		t.Fatalf("got %+v", info)
+	}
+}
@@ -0,0 +1,147 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func LPush(tag, key string, values ...interface{}) (int64, error) {
+	var n int64
+	err := Do(&n, tag, "LPUSH", key, values...)
+	return n, err
+}
+
+func RPush(tag, key string, values ...interface{}) (int64, error) {
+	var n int64
+	err := Do(&n, tag, "RPUSH", key, values...)
+	return n, err
+}
+
+// LRange returns an empty (non-nil) slice when the range or the key is empty.
+func LRange(tag, key string, start, stop int) ([]string, error) {
+	var ret []string
+	if err := Do(&ret, tag, "LRANGE", key, start, stop); err != nil {
+		return nil, err
+	}
+	if ret == nil {
+		ret = []string{}
+	}
+	return ret, nil
+}
+
+// LRangeLimit pages through a list with the offset/count of ZRangeByScore,
+// count <= 0 meaning up to the end.
+func LRangeLimit(tag, key string, offset, count int) ([]string, error) {
+	stop := -1
+	if count > 0 {
+		stop = offset + count - 1
+	}
+	return LRange(tag, key, offset, stop)
+}
+
+func listDirection(dir string) (string, error) {
+	switch d := strings.ToUpper(dir); d {
+	case "LEFT", "RIGHT":
+		return d, nil
+	}
+	return "", fmt.Errorf("%w: list direction [%s], want LEFT or RIGHT", ErrBadArgs, dir)
+}
+
+// LMove atomically pops a value from the srcDir end (LEFT or RIGHT) of src
+// and pushes it to the dstDir end of dst (Redis 6.2), returning the value.
+// An empty src gives ErrKeyNotFound. On cluster src and dst must share a slot.
+func LMove(tag, src, dst, srcDir, dstDir string) (string, error) {
+	from, err := listDirection(srcDir)
+	if err != nil {
+		return "", err
+	}
+	to, err := listDirection(dstDir)
+	if err != nil {
+		return "", err
+	}
+	if err := checkSameSlot(tag, src, dst); err != nil {
+		return "", err
+	}
+	if err := requireVersion(tag, "6.2.0"); err != nil {
+		return "", err
+	}
+
+	var value string
+	mn := radix.MaybeNil{Rcv: &value}
+	if err := DoCmd(&mn, tag, "LMOVE", src, dst, from, to); err != nil {
+		return "", err
+	}
+	if mn.Nil {
+		return "", fmt.Errorf("%w [%s]", ErrKeyNotFound, src)
+	}
+	return value, nil
+}
+
+// LIndex returns false when index is out of range or key doesn't exist.
+func LIndex(tag, key string, index int64) (string, bool, error) {
+	var value string
+	ok, err := doMaybe(&value, tag, "LINDEX", key, index)
+	return value, ok, err
+}
+
+func LLen(tag, key string) (int64, error) {
+	var n int64
+	err := Do(&n, tag, "LLEN", key)
+	return n, err
+}
+
+// LMPop pops up to count values from the first non-empty list of keys
+// (Redis 7), from the head when left is true. An empty key and nil values
+// mean all lists were empty.
+func LMPop(tag string, keys []string, left bool, count int) (key string, values []string, err error) {
+	if err = requireVersion(tag, "7.0.0"); err != nil {
+		return "", nil, err
+	}
+	where := "RIGHT"
+	if left {
+		where = "LEFT"
+	}
+	args := append([]string{strconv.Itoa(len(keys))}, keys...)
+	args = append(args, where)
+	if count > 0 {
+		args = append(args, "COUNT", strconv.Itoa(count))
+	}
+
+	var reply []interface{}
+	if err = DoCmd(&reply, tag, "LMPOP", args...); err != nil || len(reply) == 0 {
+		return "", nil, err
+	}
+	if len(reply) != 2 {
+		return "", nil, fmt.Errorf("Unexpected LMPOP reply of %d elements", len(reply))
+	}
+	if key, err = replyString(reply[0]); err != nil {
+		return "", nil, err
+	}
+	if values, err = replyStrings(reply[1]); err != nil {
+		return "", nil, err
+	}
+	return key, values, nil
+}
+
+// LPos returns the indexes of element in the list, an empty slice when it is
+// not found. rank skips matches, negative ranks search from the tail, 0 means
+// no RANK. count caps the matches returned, 0 returns all of them.
+func LPos(tag, key, element string, rank, count int) ([]int64, error) {
+	args := []interface{}{element}
+	if rank != 0 {
+		args = append(args, "RANK", rank)
+	}
+	args = append(args, "COUNT", count)
+
+	var ret []int64
+	if err := Do(&ret, tag, "LPOS", key, args...); err != nil {
+		return nil, err
+	}
+	if ret == nil {
+		ret = []int64{}
+	}
+	return ret, nil
+}
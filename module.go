@@ -0,0 +1,63 @@
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+// moduleErr turns the unknown command error of a missing module into
+// ErrModuleMissing.
+func moduleErr(module string, err error) error {
+	if isUnknownCommandErr(err) {
+		return fmt.Errorf("%w: %s", ErrModuleMissing, module)
+	}
+	return err
+}
+
+// JSONSet marshals v and stores it at path of key with RedisJSON, "$" being
+// the root of the document.
+func JSONSet(tag, key, path string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var ret string
+	return moduleErr("RedisJSON", DoCmd(&ret, tag, "JSON.SET", key, path, string(raw)))
+}
+
+// JSONGet returns the JSON at paths of key as is, nil when key doesn't exist.
+func JSONGet(tag, key string, paths ...string) (json.RawMessage, error) {
+	var ret []byte
+	mn := radix.MaybeNil{Rcv: &ret}
+	if err := DoCmd(&mn, tag, "JSON.GET", append([]string{key}, paths...)...); err != nil {
+		return nil, moduleErr("RedisJSON", err)
+	}
+	if mn.Nil {
+		return nil, nil
+	}
+	return json.RawMessage(ret), nil
+}
+
+// BFAdd adds item to the RedisBloom filter key, it returns false when the
+// item may have been added before.
+func BFAdd(tag, key, item string) (bool, error) {
+	var n int64
+	err := DoCmd(&n, tag, "BF.ADD", key, item)
+	return n == 1, moduleErr("RedisBloom", err)
+}
+
+func BFExists(tag, key, item string) (bool, error) {
+	var n int64
+	err := DoCmd(&n, tag, "BF.EXISTS", key, item)
+	return n == 1, moduleErr("RedisBloom", err)
+}
+
+func BFReserve(tag, key string, errorRate float64, capacity int64) error {
+	var ret string
+	err := DoCmd(&ret, tag, "BF.RESERVE", key,
+		strconv.FormatFloat(errorRate, 'f', -1, 64), strconv.FormatInt(capacity, 10))
+	return moduleErr("RedisBloom", err)
+}
@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// holds a func(interface{}), nil when no handler is set
+var panicHandler atomic.Value
+
+// SetPanicHandler is called with the recovered value whenever a callback
+// given to this package (OnConnect, OnClose, StartPoolMetrics, SetOnConsume)
+// panics. The panic is always logged and never crashes the goroutine running
+// the callback. nil removes the handler.
+func SetPanicHandler(h func(recovered interface{})) {
+	panicHandler.Store(h)
+}
+
+// safeCall runs the user callback fn, recovering a panic so it can't take
+// down the pool or background goroutines. It returns whether fn panicked.
+func safeCall(name string, fn func()) (panicked bool) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		panicked = true
+		logWarn("redis.%s panicked: %v\n%s", name, r, debug.Stack())
+		if h, _ := panicHandler.Load().(func(interface{})); h != nil {
+			callPanicHandler(name, h, r)
+		}
+	}()
+	fn()
+	return false
+}
+
+// callPanicHandler runs h, a panic of the handler itself is logged and
+// dropped.
+func callPanicHandler(name string, h func(interface{}), recovered interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			logWarn("redis.%s panic handler panicked: %v\n%s", name, r, debug.Stack())
+		}
+	}()
+	h(recovered)
+}
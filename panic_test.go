@@ -0,0 +1,19 @@
+package redis
+
+import "testing"
+
+func TestSafeCallHandlerPanics(t *testing.T) {
+	var got interface{}
+	SetPanicHandler(func(r interface{}) {
+		got = r
+		panic("handler")
+	})
+	defer SetPanicHandler(nil)
+
+	if !safeCall("Test", func() { panic("callback") }) {
+		t.Fatal("panic of the callback not reported")
+	}
+	if got != "callback" {
+		t.Fatalf("handler got %v, want callback", got)
+	}
+}
@@ -0,0 +1,99 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// CmdResult is the outcome of one command of a Pipeline. Value is the
+// receiver given to Add, or the reply tree (see DoTree) when it was nil.
+type CmdResult struct {
+	Cmd   string
+	Args  []string
+	Value interface{}
+	Err   error
+}
+
+// Pipeline sends its commands in one round trip on a single connection.
+// Unlike Tx it is not atomic: commands of other clients may run in between
+// and every command succeeds or fails on its own, a failed command doesn't
+// stop the others. On cluster all keys must be in the same slot.
+type Pipeline struct {
+	tag     string
+	key     string
+	cmds    []txCmd
+	results []CmdResult
+}
+
+func NewPipeline(tag string) *Pipeline {
+	return &Pipeline{tag: tag}
+}
+
+func (p *Pipeline) Add(rcv interface{}, cmd string, args ...string) *Pipeline {
+	cmd = normalizeCmd(cmd)
+	if len(p.key) == 0 {
+		if keys := radix.Cmd(nil, cmd, args...).Keys(); len(keys) > 0 {
+			p.key = keys[0]
+		}
+	}
+	p.cmds = append(p.cmds, txCmd{rcv: rcv, cmd: cmd, args: args})
+	return p
+}
+
+// Exec only fails when the pipeline couldn't be sent or read, errors of
+// single commands are reported by Results.
+func (p *Pipeline) Exec() error {
+	t := time.Now()
+	var err error
+	defer func() {
+		logInfo("redis.Pipeline cost:%v tag:%s cmds:%d err:%v", time.Since(t), p.tag, len(p.cmds), err)
+	}()
+
+	client, err := getClientByTag(p.tag)
+	if err != nil {
+		return err
+	}
+	for _, c := range p.cmds {
+		if err = checkCommand(p.tag, c.cmd); err != nil {
+			return err
+		}
+	}
+
+	raws := make([]resp2.RawMessage, len(p.cmds))
+	actions := make([]radix.CmdAction, len(p.cmds))
+	for i, c := range p.cmds {
+		actions[i] = radix.Cmd(&raws[i], c.cmd, c.args...)
+	}
+	err = wrapErr(p.tag, "PIPELINE", doGuarded(p.tag, radix.WithConn(p.key, func(conn radix.Conn) error {
+		return conn.Do(radix.Pipeline(actions...))
+	}), client.Do))
+	for _, c := range p.cmds {
+		invalidateCached(p.tag, c.cmd, c.args)
+	}
+	if err != nil {
+		return err
+	}
+
+	p.results = make([]CmdResult, len(p.cmds))
+	for i, c := range p.cmds {
+		r := CmdResult{Cmd: c.cmd, Args: c.args, Value: c.rcv}
+		if c.rcv == nil {
+			var tree interface{}
+			r.Err = raws[i].UnmarshalInto(&resp2.Any{I: &tree})
+			r.Value = tree
+		} else {
+			r.Err = raws[i].UnmarshalInto(&resp2.Any{I: c.rcv})
+		}
+		r.Err = wrapErr(p.tag, c.cmd, r.Err)
+		p.results[i] = r
+	}
+	return nil
+}
+
+// Results returns one CmdResult per command in the order they were added,
+// it is empty until Exec succeeded.
+func (p *Pipeline) Results() []CmdResult {
+	return p.results
+}
@@ -0,0 +1,81 @@
+package redis
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+// CommandPolicy restricts the commands a tag may run. With a non-empty Allow
+// only the listed commands pass, Deny is checked first and always wins.
+// Names are case-insensitive and matched against the first word of the
+// command, so denying CONFIG also denies CONFIG GET.
+type CommandPolicy struct {
+	Allow []string
+	Deny  []string
+}
+
+type commandPolicy struct {
+	allow map[string]bool
+	deny  map[string]bool
+}
+
+// key - tag
+// value - *commandPolicy
+var policyMap sync.Map
+
+// SetCommandPolicy replaces the policy of tag, a zero CommandPolicy lifts
+// all restrictions.
+func SetCommandPolicy(tag string, policy CommandPolicy) {
+	if len(policy.Allow) == 0 && len(policy.Deny) == 0 {
+		policyMap.Delete(tag)
+		return
+	}
+
+	p := &commandPolicy{}
+	if len(policy.Allow) > 0 {
+		p.allow = make(map[string]bool, len(policy.Allow))
+		for _, cmd := range policy.Allow {
+			p.allow[strings.ToUpper(cmd)] = true
+		}
+	}
+	p.deny = make(map[string]bool, len(policy.Deny))
+	for _, cmd := range policy.Deny {
+		p.deny[strings.ToUpper(cmd)] = true
+	}
+	policyMap.Store(tag, p)
+	logInfo("redis.SetCommandPolicy tag:%s policy:%+v", tag, policy)
+}
+
+// doChecked is how commands that don't go through Do, DoCmd or Eval are sent:
+// every name in cmds, the commands a carries, is checked against the policy of
+// tag before a runs on client, the client of tag or of one of its nodes.
+func doChecked(tag string, client radix.Client, a radix.Action, cmds ...string) error {
+	for _, cmd := range cmds {
+		if err := checkCommand(tag, cmd); err != nil {
+			return err
+		}
+	}
+	return doGuarded(tag, a, client.Do)
+}
+
+// checkCommand returns ErrCommandDenied when the policy of tag forbids cmd.
+func checkCommand(tag, cmd string) error {
+	v, ok := policyMap.Load(tag)
+	if !ok {
+		return nil
+	}
+	p := v.(*commandPolicy)
+
+	name := cmd
+	if fields := strings.Fields(cmd); len(fields) > 0 {
+		name = fields[0]
+	}
+	name = strings.ToUpper(name)
+	if p.deny[name] || (p.allow != nil && !p.allow[name]) {
+		return fmt.Errorf("%w: %s on tag [%s]", ErrCommandDenied, name, tag)
+	}
+	return nil
+}
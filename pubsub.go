@@ -0,0 +1,337 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+var subscribeMinBackoff = 100 * time.Millisecond
+var subscribeMaxBackoff = 10 * time.Second
+var subscribePingInterval = 5 * time.Second
+
+// a connection that stayed subscribed this long resets the backoff, one that
+// drops sooner keeps increasing it so a flapping node isn't hammered
+var subscribeStableAfter = 30 * time.Second
+
+var errSubscriptionClosed = errors.New("Subscription closed")
+
+// key - *Subscription
+// value - tag, its subscriptions are closed along with it by Close and
+// Destory
+var subscriptionMap sync.Map
+
+// seeded per process so a fleet restarted together doesn't draw the same
+// delays
+var jitterMu sync.Mutex
+var jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+// jitter returns a random duration in [0, d), "full jitter" backoff.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	jitterMu.Lock()
+	defer jitterMu.Unlock()
+	return time.Duration(jitterRand.Int63n(int64(d)))
+}
+
+const (
+	SubscribeOverflowBlock      = "block"
+	SubscribeOverflowDropOldest = "drop-oldest"
+)
+
+// SubscribeOptions controls the buffering of Messages(). When the buffer is
+// full, Overflow decides what happens to new messages:
+//
+//	block       - stop reading the connection until the consumer catches up,
+//	              Redis then buffers on its side up to its output buffer limit
+//	drop-oldest - discard the oldest buffered message, counted by Dropped()
+//
+// Either way Redis pub/sub is at-most-once, messages published while
+// reconnecting are lost.
+//
+// Jitter waits a random time up to the backoff before reconnecting instead of
+// the backoff itself, so many instances don't reconnect to a recovered node
+// all at once.
+type SubscribeOptions struct {
+	BufferSize int
+	Overflow   string
+	Jitter     bool
+}
+
+// Subscription keeps a dedicated pub/sub connection to the node of a tag.
+// When the connection drops it is re-dialed with exponential backoff and all
+// channels are subscribed again; Messages() is only closed by Close().
+type Subscription struct {
+	// accessed atomically, kept first for 64-bit alignment
+	dropped uint64
+
+	tag      string
+	channels []string
+	opts     SubscribeOptions
+
+	msgCh   chan radix.PubSubMessage
+	errCh   chan error
+	closeCh chan struct{}
+
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func Subscribe(tag string, channels ...string) (*Subscription, error) {
+	return SubscribeWithOptions(tag, SubscribeOptions{}, channels...)
+}
+
+// SubscribeCtx is Subscribe that closes the subscription, as Close does, once
+// ctx is done.
+func SubscribeCtx(ctx context.Context, tag string, channels ...string) (*Subscription, error) {
+	return subscribe(ctx, tag, SubscribeOptions{}, channels)
+}
+
+func SubscribeWithOptions(tag string, opts SubscribeOptions, channels ...string) (*Subscription, error) {
+	return subscribe(context.Background(), tag, opts, channels)
+}
+
+func subscribe(ctx context.Context, tag string, opts SubscribeOptions, channels []string) (*Subscription, error) {
+	if len(channels) == 0 {
+		return nil, errors.New("Subscribe needs at least one channel")
+	}
+	switch opts.Overflow {
+	case "":
+		opts.Overflow = SubscribeOverflowBlock
+	case SubscribeOverflowBlock, SubscribeOverflowDropOldest:
+	default:
+		return nil, fmt.Errorf("Unknown subscribe overflow [%s], want block or drop-oldest", opts.Overflow)
+	}
+	if opts.BufferSize < 0 {
+		opts.BufferSize = 0
+	}
+	if opts.Overflow == SubscribeOverflowDropOldest && opts.BufferSize == 0 {
+		// there is nothing to drop from without a buffer
+		opts.BufferSize = 1
+	}
+	if _, err := getClientByTag(tag); err != nil {
+		return nil, err
+	}
+	if err := checkCommand(tag, "SUBSCRIBE"); err != nil {
+		return nil, err
+	}
+
+	s := &Subscription{
+		tag:      tag,
+		channels: channels,
+		opts:     opts,
+		msgCh:    make(chan radix.PubSubMessage, opts.BufferSize),
+		errCh:    make(chan error, 16),
+		closeCh:  make(chan struct{}),
+	}
+	subscriptionMap.Store(s, tag)
+	s.wg.Add(1)
+	go s.run()
+	if ctx.Done() != nil {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			select {
+			case <-ctx.Done():
+				s.stop()
+			case <-s.closeCh:
+			}
+		}()
+	}
+
+	logInfo("redis.Subscribe tag:%s channels:%v", tag, channels)
+	return s, nil
+}
+
+// Publish sends message, flattened like the args of Do, to channel and
+// returns how many subscribers received it. On cluster that is the count of
+// the node the message was sent to only.
+func Publish(tag, channel string, message interface{}) (int64, error) {
+	var n int64
+	err := Do(&n, tag, "PUBLISH", channel, message)
+	return n, err
+}
+
+// SPublish publishes to a shard channel (Redis 7), which on cluster stays
+// within the shard owning the slot of channel.
+func SPublish(tag, channel string, message interface{}) (int64, error) {
+	if err := requireVersion(tag, "7.0.0"); err != nil {
+		return 0, err
+	}
+	var n int64
+	err := Do(&n, tag, "SPUBLISH", channel, message)
+	return n, err
+}
+
+func (s *Subscription) Messages() <-chan radix.PubSubMessage {
+	return s.msgCh
+}
+
+// Errors reports connection failures and reconnect attempts. Reading it is
+// optional, errors are dropped when nobody keeps up with them.
+func (s *Subscription) Errors() <-chan error {
+	return s.errCh
+}
+
+func (s *Subscription) Close() error {
+	s.stop()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Subscription) stop() {
+	s.closeOnce.Do(func() {
+		close(s.closeCh)
+	})
+}
+
+// closeSubscriptions closes the subscriptions of tag and waits for their
+// goroutines to end.
+func closeSubscriptions(tag string) {
+	subscriptionMap.Range(func(k, v interface{}) bool {
+		if v.(string) == tag {
+			k.(*Subscription).Close()
+		}
+		return true
+	})
+}
+
+// Dropped returns how many messages the drop-oldest overflow discarded.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// deliver hands m to the consumer according to the overflow policy, it
+// returns false when the subscription got closed meanwhile.
+func (s *Subscription) deliver(m radix.PubSubMessage) bool {
+	if s.opts.Overflow != SubscribeOverflowDropOldest {
+		select {
+		case s.msgCh <- m:
+			return true
+		case <-s.closeCh:
+			return false
+		}
+	}
+
+	for {
+		select {
+		case s.msgCh <- m:
+			return true
+		case <-s.closeCh:
+			return false
+		default:
+		}
+		select {
+		case <-s.msgCh:
+			atomic.AddUint64(&s.dropped, 1)
+		default:
+		}
+	}
+}
+
+func (s *Subscription) closed() bool {
+	select {
+	case <-s.closeCh:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Subscription) reportErr(err error) {
+	select {
+	case s.errCh <- err:
+	default:
+	}
+}
+
+func (s *Subscription) run() {
+	defer s.wg.Done()
+	defer close(s.msgCh)
+	defer subscriptionMap.Delete(s)
+
+	backoff := subscribeMinBackoff
+	for {
+		up, err := s.serve()
+		if s.closed() {
+			return
+		}
+		if up >= subscribeStableAfter {
+			backoff = subscribeMinBackoff
+		}
+
+		wait := backoff
+		if s.opts.Jitter {
+			wait = jitter(backoff)
+		}
+		logWarn("redis.Subscribe tag:%s channels:%v err:%v, reconnect in %v", s.tag, s.channels, err, wait)
+		s.reportErr(fmt.Errorf("Subscription of tag [%s] reconnect in %v: %w", s.tag, wait, err))
+
+		select {
+		case <-time.After(wait):
+		case <-s.closeCh:
+			return
+		}
+		if backoff *= 2; backoff > subscribeMaxBackoff {
+			backoff = subscribeMaxBackoff
+		}
+	}
+}
+
+// serve subscribes on a new connection and forwards messages until the
+// connection fails or the subscription is closed. It returns how long the
+// connection was subscribed.
+func (s *Subscription) serve() (up time.Duration, err error) {
+	conn, err := dialTag(s.tag)
+	if err != nil {
+		return 0, err
+	}
+
+	psc := radix.PubSub(conn)
+	inner := make(chan radix.PubSubMessage)
+	defer func() {
+		// keep draining so the reader of psc can't block on inner while closing
+		stop := make(chan struct{})
+		go func() {
+			for {
+				select {
+				case <-inner:
+				case <-stop:
+					return
+				}
+			}
+		}()
+		psc.Close()
+		close(stop)
+	}()
+
+	if err = psc.Subscribe(inner, s.channels...); err != nil {
+		return 0, err
+	}
+	subscribedAt := time.Now()
+
+	ticker := time.NewTicker(subscribePingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case m := <-inner:
+			if !s.deliver(m) {
+				return time.Since(subscribedAt), errSubscriptionClosed
+			}
+		case <-ticker.C:
+			if err = psc.Ping(); err != nil {
+				return time.Since(subscribedAt), err
+			}
+		case <-s.closeCh:
+			return time.Since(subscribedAt), errSubscriptionClosed
+		}
+	}
+}
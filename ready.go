@@ -0,0 +1,61 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+var readyMinBackoff = 100 * time.Millisecond
+var readyMaxBackoff = 5 * time.Second
+
+// WaitReady PINGs every primary of every initialized tag until all of them
+// answer, retrying with backoff, e.g. as a readiness gate before taking
+// traffic. Once ctx is done it returns its error together with the tags
+// still failing.
+func WaitReady(ctx context.Context) error {
+	t := time.Now()
+	backoff := readyMinBackoff
+	for {
+		failing := pingAll()
+		if len(failing) == 0 {
+			logInfo("redis.WaitReady cost:%v", time.Since(t))
+			return nil
+		}
+		logWarn("redis.WaitReady not ready, retry in %v: %v", backoff, failing)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("%w, tags not ready: %v", ctx.Err(), failing)
+		}
+		if backoff *= 2; backoff > readyMaxBackoff {
+			backoff = readyMaxBackoff
+		}
+	}
+}
+
+// pingAll returns the error of every tag with a primary not answering PING,
+// keyed by tag.
+func pingAll() NodeErrors {
+	failing := NodeErrors{}
+	clientMap.Range(func(k, v interface{}) bool {
+		tag := k.(string)
+		nodes, err := primaryClients(tag)
+		if err != nil {
+			failing[tag] = err
+			return true
+		}
+		for _, n := range nodes {
+			var pong string
+			if err := doChecked(tag, n.client, radix.Cmd(&pong, "PING"), "PING"); err != nil {
+				failing[tag] = wrapErr(tag, "PING", err)
+				break
+			}
+		}
+		return true
+	})
+	return failing
+}
@@ -1,14 +1,22 @@
 package redis
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net"
+	"os"
 	"reflect"
+	"runtime/debug"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mediocregopher/radix/v3"
@@ -21,6 +29,18 @@ type Socks5ProxyConfig struct {
 	Addr string `json:"addr"`
 }
 
+// TLSConfig enables TLS to the servers. Without CAFile the system roots are
+// trusted, CertFile and KeyFile give a client certificate. An empty
+// ServerName is taken from the address dialed.
+type TLSConfig struct {
+	Enabled            bool   `json:"enabled"`
+	CAFile             string `json:"ca_file"`
+	CertFile           string `json:"cert_file"`
+	KeyFile            string `json:"key_file"`
+	ServerName         string `json:"server_name"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+}
+
 // json config example:
 // {
 // 	"redis-standalone": [
@@ -28,7 +48,11 @@ type Socks5ProxyConfig struct {
 // 			"tag": "s1",
 // 			"addr": "127.0.0.1:6379",
 // 			"timeout": 2000,
+// 			"read_timeout": 500,
 // 			"pool_size": 20,
+// 			"pool_overflow": "block",
+// 			"pool_ping_interval": 30000,
+// 			"db": 2,
 //			"socks5":{"user","u", "pass":"p", "addr":"127.0.0.1:8888"}
 // 		}
 // 	],
@@ -51,27 +75,77 @@ type Socks5ProxyConfig struct {
 // }
 //
 type StandaloneConfig struct {
-	Tag      string            `json:"tag"`
-	Addr     string            `json:"addr"`
-	Timeout  int               `json:"timeout"`
-	PoolSize int               `json:"pool_size"`
-	Socks5   Socks5ProxyConfig `json:"socks5"`
+	Tag              string            `json:"tag"`
+	Addr             string            `json:"addr"`
+	Timeout          int               `json:"timeout"`
+	ConnectTimeout   int               `json:"connect_timeout"`
+	ReadTimeout      int               `json:"read_timeout"`
+	WriteTimeout     int               `json:"write_timeout"`
+	PoolSize         int               `json:"pool_size"`
+	PoolOverflow     string            `json:"pool_overflow"`
+	ConnLeakTimeout  int               `json:"conn_leak_timeout"`
+	PoolPingInterval int               `json:"pool_ping_interval"`
+	ClientNoEvict    bool              `json:"client_no_evict"`
+	ClientNoTouch    bool              `json:"client_no_touch"`
+	ResetConn        bool              `json:"reset_conn"`
+	Socks5           Socks5ProxyConfig `json:"socks5"`
+	TLS              TLSConfig         `json:"tls"`
+	Username         string            `json:"username"`
+	Password         string            `json:"password"`
+	DB               int               `json:"db"`
+
+	OnConnect func(conn radix.Conn) error `json:"-"`
+	OnClose   func()                      `json:"-"`
 }
 
 type SentinelConfig struct {
-	MasterTag map[string]string `json:"master_tag"`
-	Addrs     []string          `json:"addrs"`
-	Timeout   int               `json:"timeout"`
-	PoolSize  int               `json:"pool_size"`
-	Socks5    Socks5ProxyConfig `json:"socks5"`
+	MasterTag        map[string]string `json:"master_tag"`
+	Addrs            []string          `json:"addrs"`
+	Timeout          int               `json:"timeout"`
+	ConnectTimeout   int               `json:"connect_timeout"`
+	ReadTimeout      int               `json:"read_timeout"`
+	WriteTimeout     int               `json:"write_timeout"`
+	PoolSize         int               `json:"pool_size"`
+	PoolOverflow     string            `json:"pool_overflow"`
+	ConnLeakTimeout  int               `json:"conn_leak_timeout"`
+	PoolPingInterval int               `json:"pool_ping_interval"`
+	ClientNoEvict    bool              `json:"client_no_evict"`
+	ClientNoTouch    bool              `json:"client_no_touch"`
+	ResetConn        bool              `json:"reset_conn"`
+	ReplicaFallback  bool              `json:"replica_fallback"`
+	Socks5           Socks5ProxyConfig `json:"socks5"`
+	TLS              TLSConfig         `json:"tls"`
+	Username         string            `json:"username"`
+	Password         string            `json:"password"`
+	SentinelPassword string            `json:"sentinel_password"`
+	DB               int               `json:"db"`
+
+	OnConnect func(conn radix.Conn) error `json:"-"`
+	OnClose   func()                      `json:"-"`
 }
 
 type ClusterConfig struct {
-	Tag      string            `json:"tag"`
-	Addrs    []string          `json:"addrs"`
-	Timeout  int               `json:"timeout"`
-	PoolSize int               `json:"pool_size"`
-	Socks5   Socks5ProxyConfig `json:"socks5"`
+	Tag              string            `json:"tag"`
+	Addrs            []string          `json:"addrs"`
+	Timeout          int               `json:"timeout"`
+	ConnectTimeout   int               `json:"connect_timeout"`
+	ReadTimeout      int               `json:"read_timeout"`
+	WriteTimeout     int               `json:"write_timeout"`
+	PoolSize         int               `json:"pool_size"`
+	PoolOverflow     string            `json:"pool_overflow"`
+	ConnLeakTimeout  int               `json:"conn_leak_timeout"`
+	PoolPingInterval int               `json:"pool_ping_interval"`
+	ClientNoEvict    bool              `json:"client_no_evict"`
+	ClientNoTouch    bool              `json:"client_no_touch"`
+	ResetConn        bool              `json:"reset_conn"`
+	ReplicaFallback  bool              `json:"replica_fallback"`
+	Socks5           Socks5ProxyConfig `json:"socks5"`
+	TLS              TLSConfig         `json:"tls"`
+	Username         string            `json:"username"`
+	Password         string            `json:"password"`
+
+	OnConnect func(conn radix.Conn) error `json:"-"`
+	OnClose   func()                      `json:"-"`
 }
 
 type ConfigWrapper struct {
@@ -88,6 +162,235 @@ type LuaScript struct {
 // value - client
 var clientMap sync.Map
 
+// key - tag
+// value - *tagOption
+var optionMap sync.Map
+
+// serializes replacing clients, see registerClient
+var registerMu sync.Mutex
+
+type tagOption struct {
+	// accessed atomically, kept first for 64-bit alignment
+	leaked int64
+	// bumped when the server rejects the credentials a connection was dialed
+	// with, connections of older generations reauth before use
+	authGen int64
+
+	tag     string
+	timeout time.Duration
+
+	// used to open dedicated connections, e.g. for pub/sub
+	addr     string
+	connFunc radix.ConnFunc
+	conn     connOptions
+
+	// credentials sent with AUTH on every new connection, can be changed at
+	// runtime to follow a password rotation
+	credMu   sync.RWMutex
+	username string
+	password string
+
+	leakTimeout time.Duration
+
+	// retry DoReplica on the primary when the replica can't be reached
+	replicaFallback bool
+
+	// RESET connections given back by WithConn instead of trusting their state
+	resetConn bool
+
+	// set for sentinel tags, see Refresh
+	sentinel *sentinelSource
+}
+
+func (o *tagOption) credentials() (username, password string) {
+	o.credMu.RLock()
+	defer o.credMu.RUnlock()
+	return o.username, o.password
+}
+
+// reauth sends AUTH with the current credentials again on conn when it was
+// dialed before the credentials of the tag were last rejected, so it can stay
+// in the pool. When that fails too conn is closed and errStaleConn returned.
+func (o *tagOption) reauth(conn radix.Conn) error {
+	gc, ok := conn.NetConn().(*genConn)
+	if !ok {
+		return nil
+	}
+	gen := atomic.LoadInt64(&o.authGen)
+	if gc.gen >= gen {
+		return nil
+	}
+	if err := authConn(conn, o); err != nil {
+		logWarn("redis.reauth tag:%s err:%v, closing connection", o.tag, err)
+		conn.Close()
+		return errStaleConn
+	}
+	gc.gen = gen
+	return nil
+}
+
+// rejected closes conn after the server answered NOAUTH/WRONGPASS on it and
+// makes every other connection dialed with the same credentials reauth.
+func (o *tagOption) rejected(conn radix.Conn) {
+	if gc, ok := conn.NetConn().(*genConn); ok {
+		atomic.CompareAndSwapInt64(&o.authGen, gc.gen, gc.gen+1)
+	}
+	conn.Close()
+}
+
+// watchLeak warns, with the stack of the caller, when a connection is held
+// longer than the conn_leak_timeout of the tag. The returned func must be
+// called once the connection is given back.
+func (o *tagOption) watchLeak() func() {
+	if o.leakTimeout <= 0 {
+		return func() {}
+	}
+
+	// 0 - checked out, 1 - reported as leaked, 2 - given back in time
+	var state int32
+	stack := debug.Stack()
+	timer := time.AfterFunc(o.leakTimeout, func() {
+		if atomic.CompareAndSwapInt32(&state, 0, 1) {
+			atomic.AddInt64(&o.leaked, 1)
+			logWarn("redis connection of tag [%s] held over %v, checked out at:\n%s", o.tag, o.leakTimeout, stack)
+		}
+	})
+	return func() {
+		timer.Stop()
+		if !atomic.CompareAndSwapInt32(&state, 0, 2) {
+			atomic.AddInt64(&o.leaked, -1)
+		}
+	}
+}
+
+// SetPassword changes the password used by new connections of tag. Pooled
+// connections keep working until the server drops them. The first one
+// failing with NOAUTH or WRONGPASS is discarded and re-dialed with the new
+// password, the others dialed with the same password send AUTH again before
+// their next command.
+func SetPassword(tag, password string) error {
+	o, ok := optionMap.Load(tag)
+	if !ok {
+		return fmt.Errorf("%w [%s]", ErrTagNotFound, tag)
+	}
+	opt := o.(*tagOption)
+	opt.credMu.Lock()
+	opt.password = password
+	opt.credMu.Unlock()
+	logInfo("redis.SetPassword tag:%s", tag)
+	return nil
+}
+
+// authErr names tag when err is the server rejecting its credentials.
+func authErr(tag string, err error) error {
+	if ClassifyError(err) == KindAuth {
+		return fmt.Errorf("Auth of tag [%s] rejected: %w", tag, err)
+	}
+	return err
+}
+
+// checkAuth makes init fail at once when the server rejects the credentials
+// of tag, instead of on the first command.
+func checkAuth(tag string, client radix.Client, opt *tagOption) error {
+	if _, password := opt.credentials(); len(password) == 0 {
+		return nil
+	}
+	var pong string
+	if err := authErr(tag, client.Do(radix.Cmd(&pong, "PING"))); ClassifyError(err) == KindAuth {
+		return err
+	}
+	return nil
+}
+
+func redact(secret string) string {
+	if len(secret) == 0 {
+		return ""
+	}
+	return "***"
+}
+
+// redacted returns a copy of c fit for logging.
+func (c StandaloneConfig) redacted() StandaloneConfig {
+	c.Password = redact(c.Password)
+	c.Socks5.Pass = redact(c.Socks5.Pass)
+	return c
+}
+
+func (c SentinelConfig) redacted() SentinelConfig {
+	c.Password = redact(c.Password)
+	c.SentinelPassword = redact(c.SentinelPassword)
+	c.Socks5.Pass = redact(c.Socks5.Pass)
+	return c
+}
+
+func (c ClusterConfig) redacted() ClusterConfig {
+	c.Password = redact(c.Password)
+	c.Socks5.Pass = redact(c.Socks5.Pass)
+	return c
+}
+
+func (w ConfigWrapper) redacted() ConfigWrapper {
+	var r ConfigWrapper
+	for _, c := range w.StandCfg {
+		r.StandCfg = append(r.StandCfg, c.redacted())
+	}
+	for _, c := range w.SentinelCfg {
+		r.SentinelCfg = append(r.SentinelCfg, c.redacted())
+	}
+	for _, c := range w.ClusterCfg {
+		r.ClusterCfg = append(r.ClusterCfg, c.redacted())
+	}
+	return r
+}
+
+// withAuth sends AUTH with the current credentials of opt right after dial
+// and tags the connection with their generation, see tagOption.reauth.
+func withAuth(connFunc radix.ConnFunc, opt *tagOption) radix.ConnFunc {
+	return func(network, addr string) (radix.Conn, error) {
+		conn, err := connFunc(network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		gen := atomic.LoadInt64(&opt.authGen)
+		if err := authConn(conn, opt); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return &authedConn{Conn: conn, netConn: &genConn{Conn: conn.NetConn(), gen: gen}}, nil
+	}
+}
+
+// authedConn hands out its net.Conn as a genConn, which is how the
+// generation is found again once the pool wrapped the connection.
+type authedConn struct {
+	radix.Conn
+	netConn *genConn
+}
+
+func (ac *authedConn) NetConn() net.Conn {
+	return ac.netConn
+}
+
+// genConn is the net.Conn of a connection that sent AUTH in generation gen of
+// its tag.
+type genConn struct {
+	net.Conn
+	gen int64
+}
+
+func authConn(conn radix.Conn, opt *tagOption) error {
+	username, password := opt.credentials()
+	if len(password) == 0 {
+		return nil
+	}
+	args := []string{password}
+	if len(username) > 0 {
+		args = []string{username, password}
+	}
+	return conn.Do(radix.Cmd(nil, "AUTH", args...))
+}
+
 var defaultTimeout = 3000
 var defaultPoolSize = 10
 
@@ -97,6 +400,400 @@ var logStdout = func(format string, a ...interface{}) {
 var logWarn = logStdout
 var logInfo = logStdout
 
+// set by DisableInstrumentation, accessed atomically
+var instrumentationOff int32
+
+// DisableInstrumentation turns off the timing, logging and command history
+// of Do, DoContext, DoCmd and DoCmdContext for services where that per-call
+// overhead matters. It can't be turned on again.
+func DisableInstrumentation() {
+	atomic.StoreInt32(&instrumentationOff, 1)
+	logWarn("redis.DisableInstrumentation, commands are no longer logged")
+}
+
+func instrumented() bool {
+	return atomic.LoadInt32(&instrumentationOff) == 0
+}
+
+type hookConn struct {
+	radix.Conn
+	onClose   func()
+	closeOnce sync.Once
+}
+
+func (hc *hookConn) Close() error {
+	hc.closeOnce.Do(func() {
+		safeCall("OnClose", hc.onClose)
+	})
+	return hc.Conn.Close()
+}
+
+// withConnHooks runs onConnect on every new connection, a returned error or a
+// panic closes the connection and fails the dial, and calls onClose once the
+// connection is closed.
+func withConnHooks(connFunc radix.ConnFunc, onConnect func(conn radix.Conn) error, onClose func()) radix.ConnFunc {
+	if onConnect == nil && onClose == nil {
+		return connFunc
+	}
+	return func(network, addr string) (radix.Conn, error) {
+		conn, err := connFunc(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if onConnect != nil {
+			if safeCall("OnConnect", func() { err = onConnect(conn) }) {
+				err = fmt.Errorf("OnConnect of %s panicked", addr)
+			}
+			if err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+		if onClose != nil {
+			conn = &hookConn{Conn: conn, onClose: onClose}
+		}
+		return conn, nil
+	}
+}
+
+// connTimeouts are the connect, read and write timeouts of a connection.
+type connTimeouts struct {
+	connect, read, write time.Duration
+}
+
+// pickTimeouts falls back to timeout for each of connect, read and write that
+// is unset. All values are in milliseconds.
+func pickTimeouts(timeout, connect, read, write int) connTimeouts {
+	pick := func(t int) time.Duration {
+		if t <= 0 {
+			t = timeout
+		}
+		return time.Duration(t) * time.Millisecond
+	}
+	return connTimeouts{connect: pick(connect), read: pick(read), write: pick(write)}
+}
+
+// deadlineConn sets the read and write timeouts before every call, as
+// radix.Dial does for the connections it opens. Once expired every call
+// fails with a timeout, a deadline set for the next call can't undo it.
+type deadlineConn struct {
+	net.Conn
+	read, write time.Duration
+
+	// set by expire, accessed atomically
+	expired int32
+}
+
+func (dc *deadlineConn) Read(b []byte) (int, error) {
+	if dc.read > 0 {
+		dc.Conn.SetReadDeadline(time.Now().Add(dc.read))
+	}
+	// checked after the deadline was set, an expire racing with this call
+	// sets its past deadline later and still interrupts the read
+	if atomic.LoadInt32(&dc.expired) == 1 {
+		return 0, os.ErrDeadlineExceeded
+	}
+	return dc.Conn.Read(b)
+}
+
+func (dc *deadlineConn) Write(b []byte) (int, error) {
+	if dc.write > 0 {
+		dc.Conn.SetWriteDeadline(time.Now().Add(dc.write))
+	}
+	if atomic.LoadInt32(&dc.expired) == 1 {
+		return 0, os.ErrDeadlineExceeded
+	}
+	return dc.Conn.Write(b)
+}
+
+// expire interrupts the call in progress and fails all later ones.
+func (dc *deadlineConn) expire() {
+	atomic.StoreInt32(&dc.expired, 1)
+	dc.Conn.SetDeadline(time.Now())
+}
+
+// expireConn expires c, the net.Conn of a pooled connection, see
+// deadlineConn.
+func expireConn(c net.Conn) {
+	for {
+		switch cc := c.(type) {
+		case *deadlineConn:
+			cc.expire()
+			return
+		case *genConn:
+			c = cc.Conn
+		default:
+			c.SetDeadline(time.Now())
+			return
+		}
+	}
+}
+
+// withClientFlags turns on CLIENT NO-EVICT and CLIENT NO-TOUCH (Redis 7) on
+// every new connection. Servers that don't know them only get a warning.
+func withClientFlags(connFunc radix.ConnFunc, noEvict, noTouch bool) radix.ConnFunc {
+	if !noEvict && !noTouch {
+		return connFunc
+	}
+	return func(network, addr string) (radix.Conn, error) {
+		conn, err := connFunc(network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := setClientFlags(conn, addr, noEvict, noTouch); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+// withSelect sends SELECT on every new connection when a DB other than 0 is
+// configured. It runs right after AUTH, which the server requires first.
+func withSelect(connFunc radix.ConnFunc, db int) radix.ConnFunc {
+	if db == 0 {
+		return connFunc
+	}
+	return func(network, addr string) (radix.Conn, error) {
+		conn, err := connFunc(network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := selectDB(conn, db); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+func selectDB(conn radix.Conn, db int) error {
+	if db == 0 {
+		return nil
+	}
+	return conn.Do(radix.Cmd(nil, "SELECT", strconv.Itoa(db)))
+}
+
+// withReadOnly sends READONLY on every new connection of a cluster, like
+// radix's DefaultClusterConnFunc, so replicas serve DoSecondary instead of
+// answering MOVED. Primaries ignore it.
+func withReadOnly(connFunc radix.ConnFunc) radix.ConnFunc {
+	return func(network, addr string) (radix.Conn, error) {
+		conn, err := connFunc(network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := setReadOnly(conn, addr); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+func setReadOnly(conn radix.Conn, addr string) error {
+	err := conn.Do(radix.Cmd(nil, "READONLY"))
+	if isReplyErr(err) {
+		logWarn("redis.READONLY not supported by %s: %v", addr, err)
+		return nil
+	}
+	return err
+}
+
+func setClientFlags(conn radix.Conn, addr string, noEvict, noTouch bool) error {
+	var flags []string
+	if noEvict {
+		flags = append(flags, "NO-EVICT")
+	}
+	if noTouch {
+		flags = append(flags, "NO-TOUCH")
+	}
+	for _, flag := range flags {
+		err := conn.Do(radix.Cmd(nil, "CLIENT", flag, "on"))
+		if isReplyErr(err) {
+			logWarn("redis.CLIENT %s not supported by %s: %v", flag, addr, err)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	PoolOverflowBlock = "block"
+	PoolOverflowError = "error"
+	PoolOverflowBurst = "burst"
+)
+
+// poolOverflowOpts maps the pool_overflow config to radix options, what
+// happens when all connections of a pool are in use:
+//
+//	block - wait for a connection to be put back
+//	error - fail at once with ErrPoolExhausted
+//	burst - open a temporary connection, closed again once put back
+//
+// Leaving it empty keeps radix's default.
+func poolOverflowOpts(policy string) ([]radix.PoolOpt, error) {
+	switch strings.ToLower(policy) {
+	case "":
+		return nil, nil
+	case PoolOverflowBlock:
+		return []radix.PoolOpt{radix.PoolOnEmptyWait()}, nil
+	case PoolOverflowError:
+		return []radix.PoolOpt{radix.PoolOnEmptyErrAfter(0)}, nil
+	case PoolOverflowBurst:
+		return []radix.PoolOpt{radix.PoolOnEmptyCreateAfter(0), radix.PoolOnFullClose()}, nil
+	}
+	return nil, fmt.Errorf("Unknown pool_overflow [%s], want one of block, error, burst", policy)
+}
+
+// registerClient makes client serve tag. A client already registered for
+// tag, e.g. when InitWith runs again on a config reload, is closed once the
+// new one took over so its pool doesn't leak.
+func registerClient(tag string, client radix.Client, opt *tagOption) {
+	registerMu.Lock()
+	old, loaded := clientMap.Load(tag)
+	clientMap.Store(tag, client)
+	optionMap.Store(tag, opt)
+	serverVersions.Delete(tag)
+	registerMu.Unlock()
+
+	if loaded {
+		if err := old.(radix.Client).Close(); err != nil {
+			logWarn("redis.registerClient tag:%s close old client err:%v", tag, err)
+		}
+		logInfo("redis.registerClient tag:%s replaced old client", tag)
+	}
+	detectServerVersion(tag)
+}
+
+// connOptions holds what buildConnFunc needs to open a connection.
+type connOptions struct {
+	timeouts  connTimeouts
+	socks5    proxy.Dialer
+	tls       *tls.Config
+	opt       *tagOption
+	db        int
+	noEvict   bool
+	noTouch   bool
+	readOnly  bool
+	onConnect func(conn radix.Conn) error
+	onClose   func()
+}
+
+// socks5Dialer builds the proxy dialer once per config, nil without a proxy.
+func socks5Dialer(c Socks5ProxyConfig) (proxy.Dialer, error) {
+	if len(c.Addr) == 0 {
+		return nil, nil
+	}
+	auth := &proxy.Auth{User: c.User, Password: c.Pass}
+	pd, err := proxy.SOCKS5("tcp", c.Addr, auth, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid socks5 proxy [%s]: %w", c.Addr, err)
+	}
+	return pd, nil
+}
+
+// tlsClientConfig loads the TLS files at init, nil when TLS is off.
+func tlsClientConfig(c TLSConfig) (*tls.Config, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{ServerName: c.ServerName, InsecureSkipVerify: c.InsecureSkipVerify}
+	if len(c.CAFile) > 0 {
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Read TLS CA file [%s] failed: %w", c.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No PEM certificate in TLS CA file [%s]", c.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if len(c.CertFile) > 0 || len(c.KeyFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Load TLS cert [%s] and key [%s] failed: %w", c.CertFile, c.KeyFile, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// tlsHandshake runs TLS over a dialed connection.
+func tlsHandshake(conn net.Conn, addr string, cfg *tls.Config) (net.Conn, error) {
+	if len(cfg.ServerName) == 0 {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		cfg = cfg.Clone()
+		cfg.ServerName = host
+	}
+	tc := tls.Client(conn, cfg)
+	if err := tc.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tc, nil
+}
+
+// dialConn dials addr, through the proxy when configured. The connect timeout
+// bounds both the dial and the TLS handshake, the read and write timeouts
+// apply to every call afterwards.
+func dialConn(o connOptions, network, addr string) (radix.Conn, error) {
+	var conn net.Conn
+	var err error
+	var deadline time.Time
+	if o.timeouts.connect > 0 {
+		deadline = time.Now().Add(o.timeouts.connect)
+	}
+	if o.socks5 == nil {
+		conn, err = (&net.Dialer{Deadline: deadline}).Dial(network, addr)
+	} else if cd, ok := o.socks5.(proxy.ContextDialer); ok && !deadline.IsZero() {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		conn, err = cd.DialContext(ctx, "tcp", addr)
+		cancel()
+	} else {
+		conn, err = o.socks5.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if o.tls != nil {
+		conn.SetDeadline(deadline)
+		if conn, err = tlsHandshake(conn, addr, o.tls); err != nil {
+			return nil, err
+		}
+		conn.SetDeadline(time.Time{})
+	}
+	return radix.NewConn(&deadlineConn{Conn: conn, read: o.timeouts.read, write: o.timeouts.write}), nil
+}
+
+// buildConnFunc is the dial path shared by all client types: dial, through
+// SOCKS5 and with TLS when configured, then AUTH, the CLIENT flags and OnConnect in that
+// order, with SELECT of the DB right after AUTH. Connections to the sentinels get an opt with only their password and
+// stop after AUTH, without opt only the dial is done.
+func buildConnFunc(o connOptions) radix.ConnFunc {
+	connFunc := func(network, addr string) (radix.Conn, error) {
+		return dialConn(o, network, addr)
+	}
+	if o.opt == nil {
+		return connFunc
+	}
+	connFunc = withClientFlags(withSelect(withAuth(connFunc, o.opt), o.db), o.noEvict, o.noTouch)
+	if o.readOnly {
+		connFunc = withReadOnly(connFunc)
+	}
+	return withConnHooks(connFunc, o.onConnect, o.onClose)
+}
+
 func InitRedisStandalone(cfg []StandaloneConfig) error {
 	for _, c := range cfg {
 		var timeout, poolSize = defaultTimeout, defaultPoolSize
@@ -106,30 +803,55 @@ func InitRedisStandalone(cfg []StandaloneConfig) error {
 		if c.PoolSize > 0 {
 			poolSize = c.PoolSize
 		}
+		timeouts := pickTimeouts(timeout, c.ConnectTimeout, c.ReadTimeout, c.WriteTimeout)
+		readTimeout := timeouts.read
+		extraPoolOpts, err := poolOverflowOpts(c.PoolOverflow)
+		if err != nil {
+			return err
+		}
+		socks5, err := socks5Dialer(c.Socks5)
+		if err != nil {
+			return err
+		}
+		tlsConfig, err := tlsClientConfig(c.TLS)
+		if err != nil {
+			return err
+		}
+		if c.PoolPingInterval > 0 {
+			interval := time.Duration(c.PoolPingInterval) * time.Millisecond
+			extraPoolOpts = append(extraPoolOpts, radix.PoolPingInterval(interval))
+		}
 
-		customConnFunc := func(network, addr string) (radix.Conn, error) {
-			if len(c.Socks5.Addr) > 0 {
-				auth := &proxy.Auth{User: c.Socks5.User, Password: c.Socks5.Pass}
-				pd, err := proxy.SOCKS5("tcp", c.Socks5.Addr, auth, nil)
-				if err != nil {
-					panic(err)
-				}
-				dailer := func(addr string) (net.Conn, error) {
-					return pd.Dial("tcp", addr)
-				}
-				conn, err := dailer(addr)
-				return radix.NewConn(conn), err
-			}
-			return radix.Dial(network, addr, radix.DialTimeout(time.Duration(timeout)*time.Millisecond))
+		opt := &tagOption{tag: c.Tag, timeout: readTimeout, addr: c.Addr}
+		opt.leakTimeout = time.Duration(c.ConnLeakTimeout) * time.Millisecond
+		opt.resetConn = c.ResetConn
+		opt.username, opt.password = c.Username, c.Password
+		opt.conn = connOptions{
+			timeouts:  timeouts,
+			socks5:    socks5,
+			tls:       tlsConfig,
+			opt:       opt,
+			db:        c.DB,
+			noEvict:   c.ClientNoEvict,
+			noTouch:   c.ClientNoTouch,
+			onConnect: c.OnConnect,
+			onClose:   c.OnClose,
 		}
+		customConnFunc := buildConnFunc(opt.conn)
+		opt.connFunc = customConnFunc
 
-		client, err := radix.NewPool("tcp", c.Addr, poolSize, radix.PoolConnFunc(customConnFunc))
+		poolOpts := append([]radix.PoolOpt{radix.PoolConnFunc(customConnFunc)}, extraPoolOpts...)
+		client, err := radix.NewPool("tcp", c.Addr, poolSize, poolOpts...)
 		if err != nil {
+			return authErr(c.Tag, err)
+		}
+		if err := checkAuth(c.Tag, client, opt); err != nil {
+			client.Close()
 			return err
 		}
 
-		clientMap.Store(c.Tag, client)
-		logInfo("redis.InitRedisStandalone with %+v", c)
+		registerClient(c.Tag, client, opt)
+		logInfo("redis.InitRedisStandalone with %+v", c.redacted())
 	}
 	return nil
 }
@@ -143,42 +865,96 @@ func InitRedisSentinel(cfg []SentinelConfig) error {
 		if c.PoolSize > 0 {
 			poolSize = c.PoolSize
 		}
-
-		customConnFunc := func(network, addr string) (radix.Conn, error) {
-			if len(c.Socks5.Addr) > 0 {
-				auth := &proxy.Auth{User: c.Socks5.User, Password: c.Socks5.Pass}
-				pd, err := proxy.SOCKS5("tcp", c.Socks5.Addr, auth, nil)
-				if err != nil {
-					panic(err)
-				}
-				dailer := func(addr string) (net.Conn, error) {
-					return pd.Dial("tcp", addr)
-				}
-				conn, err := dailer(addr)
-				return radix.NewConn(conn), err
-			}
-			return radix.Dial(network, addr, radix.DialTimeout(time.Duration(timeout)*time.Millisecond))
+		timeouts := pickTimeouts(timeout, c.ConnectTimeout, c.ReadTimeout, c.WriteTimeout)
+		readTimeout := timeouts.read
+		extraPoolOpts, err := poolOverflowOpts(c.PoolOverflow)
+		if err != nil {
+			return err
 		}
-
-		customClientFunc := func(network, addr string) (radix.Client, error) {
-			return radix.NewPool(network, addr, poolSize)
+		socks5, err := socks5Dialer(c.Socks5)
+		if err != nil {
+			return err
+		}
+		tlsConfig, err := tlsClientConfig(c.TLS)
+		if err != nil {
+			return err
+		}
+		if c.PoolPingInterval > 0 {
+			interval := time.Duration(c.PoolPingInterval) * time.Millisecond
+			extraPoolOpts = append(extraPoolOpts, radix.PoolPingInterval(interval))
 		}
 
+		// connections to the sentinels themselves only need the dial and AUTH
+		// with the password of the sentinels
+		sentinelOpt := &tagOption{password: c.SentinelPassword}
+		customConnFunc := buildConnFunc(connOptions{timeouts: timeouts, socks5: socks5, tls: tlsConfig, opt: sentinelOpt})
+		sentinelAddrs := c.Addrs
+
 		for mastername, tag := range c.MasterTag {
-			client, err := radix.NewSentinel(mastername, c.Addrs,
-				radix.SentinelConnFunc(customConnFunc), radix.SentinelPoolFunc(customClientFunc))
+			opt := &tagOption{tag: tag, timeout: readTimeout}
+			opt.leakTimeout = time.Duration(c.ConnLeakTimeout) * time.Millisecond
+			opt.resetConn = c.ResetConn
+			opt.username, opt.password = c.Username, c.Password
+			opt.replicaFallback = c.ReplicaFallback
+			opt.conn = connOptions{
+				timeouts:  timeouts,
+				socks5:    socks5,
+				tls:       tlsConfig,
+				opt:       opt,
+				db:        c.DB,
+				noEvict:   c.ClientNoEvict,
+				noTouch:   c.ClientNoTouch,
+				onConnect: c.OnConnect,
+				onClose:   c.OnClose,
+			}
+			opt.connFunc = buildConnFunc(opt.conn)
+
+			poolOpts := append([]radix.PoolOpt{radix.PoolConnFunc(opt.connFunc)}, extraPoolOpts...)
+			customClientFunc := func(network, addr string) (radix.Client, error) {
+				return radix.NewPool(network, addr, poolSize, poolOpts...)
+			}
+
+			name := mastername
+			opt.sentinel = &sentinelSource{
+				name:     name,
+				connFunc: customConnFunc,
+				dial: func() (*radix.Sentinel, error) {
+					return radix.NewSentinel(name, sentinelAddrs,
+						radix.SentinelConnFunc(customConnFunc), radix.SentinelPoolFunc(customClientFunc))
+				},
+			}
+			client, err := opt.sentinel.dial()
 			if err != nil {
+				return authErr(tag, err)
+			}
+			if err := checkAuth(tag, client, opt); err != nil {
+				client.Close()
 				return err
 			}
 
-			clientMap.Store(tag, client)
-			logInfo("redis.InitRedisSentinel with %+v", c)
+			registerClient(tag, client, opt)
+			logInfo("redis.InitRedisSentinel with %+v", c.redacted())
 		}
 	}
 	return nil
 }
 
+// InitRedisCluster connects every entry of cfg before registering any of
+// them, so when one fails no tag of cfg is registered and the error names the
+// failing tag.
 func InitRedisCluster(cfg []ClusterConfig) error {
+	type initCluster struct {
+		c      ClusterConfig
+		client *radix.Cluster
+		opt    *tagOption
+	}
+	var inits []initCluster
+	closeAll := func() {
+		for _, i := range inits {
+			i.client.Close()
+		}
+	}
+
 	for _, c := range cfg {
 		var timeout, poolSize = defaultTimeout, defaultPoolSize
 		if c.Timeout > 0 {
@@ -187,33 +963,71 @@ func InitRedisCluster(cfg []ClusterConfig) error {
 		if c.PoolSize > 0 {
 			poolSize = c.PoolSize
 		}
+		timeouts := pickTimeouts(timeout, c.ConnectTimeout, c.ReadTimeout, c.WriteTimeout)
+		readTimeout := timeouts.read
+		extraPoolOpts, err := poolOverflowOpts(c.PoolOverflow)
+		if err != nil {
+			closeAll()
+			return err
+		}
+		socks5, err := socks5Dialer(c.Socks5)
+		if err != nil {
+			closeAll()
+			return err
+		}
+		tlsConfig, err := tlsClientConfig(c.TLS)
+		if err != nil {
+			closeAll()
+			return err
+		}
+		if c.PoolPingInterval > 0 {
+			interval := time.Duration(c.PoolPingInterval) * time.Millisecond
+			extraPoolOpts = append(extraPoolOpts, radix.PoolPingInterval(interval))
+		}
 
-		customConnFunc := func(network, addr string) (radix.Conn, error) {
-			if len(c.Socks5.Addr) > 0 {
-				auth := &proxy.Auth{User: c.Socks5.User, Password: c.Socks5.Pass}
-				pd, err := proxy.SOCKS5("tcp", c.Socks5.Addr, auth, nil)
-				if err != nil {
-					panic(err)
-				}
-				dailer := func(addr string) (net.Conn, error) {
-					return pd.Dial("tcp", addr)
-				}
-				conn, err := dailer(addr)
-				return radix.NewConn(conn), err
-			}
-			return radix.Dial(network, addr, radix.DialTimeout(time.Duration(timeout)*time.Millisecond))
+		opt := &tagOption{tag: c.Tag, timeout: readTimeout}
+		opt.leakTimeout = time.Duration(c.ConnLeakTimeout) * time.Millisecond
+		opt.resetConn = c.ResetConn
+		opt.username, opt.password = c.Username, c.Password
+		opt.replicaFallback = c.ReplicaFallback
+		opt.conn = connOptions{
+			timeouts:  timeouts,
+			socks5:    socks5,
+			tls:       tlsConfig,
+			opt:       opt,
+			noEvict:   c.ClientNoEvict,
+			noTouch:   c.ClientNoTouch,
+			readOnly:  true,
+			onConnect: c.OnConnect,
+			onClose:   c.OnClose,
 		}
+		customConnFunc := buildConnFunc(opt.conn)
+		opt.connFunc = customConnFunc
 
+		poolOpts := append([]radix.PoolOpt{radix.PoolConnFunc(customConnFunc)}, extraPoolOpts...)
 		customClientFunc := func(network, addr string) (radix.Client, error) {
-			return radix.NewPool(network, addr, poolSize, radix.PoolConnFunc(customConnFunc))
+			return radix.NewPool(network, addr, poolSize, poolOpts...)
 		}
 
 		client, err := radix.NewCluster(c.Addrs, radix.ClusterPoolFunc(customClientFunc))
 		if err != nil {
-			return nil
+			closeAll()
+			if err := authErr(c.Tag, err); ClassifyError(err) == KindAuth {
+				return err
+			}
+			return fmt.Errorf("Init cluster with tag [%s] failed: %w", c.Tag, err)
+		}
+		if err := checkAuth(c.Tag, client, opt); err != nil {
+			client.Close()
+			closeAll()
+			return err
 		}
-		clientMap.Store(c.Tag, client)
-		logInfo("redis.InitRedisCluster with %+v", c)
+		inits = append(inits, initCluster{c: c, client: client, opt: opt})
+	}
+
+	for _, i := range inits {
+		registerClient(i.c.Tag, i.client, i.opt)
+		logInfo("redis.InitRedisCluster with %+v", i.c.redacted())
 	}
 	return nil
 }
@@ -234,7 +1048,7 @@ func InitWith(filename string) error {
 		return err
 	}
 
-	logInfo("redis.InitWith %+v", cfgs)
+	logInfo("redis.InitWith %+v", cfgs.redacted())
 	if len(cfgs.StandCfg) > 0 {
 		err = InitRedisStandalone(cfgs.StandCfg)
 		if err != nil {
@@ -261,14 +1075,55 @@ func SetLogInfoFunc(f func(format string, a ...interface{})) {
 	logInfo = f
 }
 
+// set by SetNormalizeCommands, accessed atomically
+var normalizeOff int32
+
+// SetNormalizeCommands controls whether command names are upper cased before
+// they are sent and logged, so "get" and "GET" are reported the same way. It
+// is on by default, turn it off to pass commands through untouched.
+func SetNormalizeCommands(on bool) {
+	var v int32
+	if !on {
+		v = 1
+	}
+	atomic.StoreInt32(&normalizeOff, v)
+}
+
+func normalizeCmd(cmd string) string {
+	if atomic.LoadInt32(&normalizeOff) == 0 {
+		return strings.ToUpper(cmd)
+	}
+	return cmd
+}
+
 func Destory() {
 	clientMap.Range(func(k, v interface{}) bool {
+		closeSubscriptions(k.(string))
 		client := v.(radix.Client)
 		client.Close()
 		return true
 	})
 }
 
+// Close closes the client and the subscriptions of tag and removes the tag,
+// other tags keep working.
+func Close(tag string) error {
+	closeSubscriptions(tag)
+
+	registerMu.Lock()
+	c, ok := clientMap.Load(tag)
+	clientMap.Delete(tag)
+	optionMap.Delete(tag)
+	serverVersions.Delete(tag)
+	registerMu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w [%s]", ErrTagNotFound, tag)
+	}
+	logInfo("redis.Close tag:%s", tag)
+	return c.(radix.Client).Close()
+}
+
 func getClientByTag(tag string) (radix.Client, error) {
 	if c, ok := clientMap.Load(tag); ok {
 		var err error = nil
@@ -277,71 +1132,518 @@ func getClientByTag(tag string) (radix.Client, error) {
 		case radix.Client:
 			client, err = cc, nil
 		default:
-			client, err = nil, errors.New("Client Type err!")
+			client, err = nil, ErrClientType
 		}
 
 		return client, err
 	}
-	return nil, fmt.Errorf("Can not find client with tag [%s]", tag)
+	return nil, fmt.Errorf("%w [%s]", ErrTagNotFound, tag)
+}
+
+func getOptionByTag(tag string) *tagOption {
+	if o, ok := optionMap.Load(tag); ok {
+		return o.(*tagOption)
+	}
+	return &tagOption{tag: tag, timeout: time.Duration(defaultTimeout) * time.Millisecond}
+}
+
+// dialTag opens a dedicated connection, outside of the pool, to the node
+// serving tag. For sentinel it is the current master, for cluster a random
+// primary.
+func dialTag(tag string) (radix.Conn, error) {
+	return dialNode(tag, "")
+}
+
+// dialNode is dialTag to the primary owning key on cluster. readTimeout
+// overrides the configured one when passed, e.g. a longer one for blocking
+// commands, 0 meaning none.
+func dialNode(tag, key string, readTimeout ...time.Duration) (radix.Conn, error) {
+	client, err := getClientByTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	o := getOptionByTag(tag)
+	addr := o.addr
+	switch cc := client.(type) {
+	case *radix.Sentinel:
+		addr, _ = cc.Addrs()
+	case *radix.Cluster:
+		primaries := cc.Topo().Primaries()
+		if len(primaries) == 0 {
+			return nil, fmt.Errorf("Can not find primary of cluster with tag [%s]", tag)
+		}
+		addr = primaries[rand.Intn(len(primaries))].Addr
+		if len(key) > 0 {
+			slot := radix.ClusterSlot([]byte(key))
+			for _, p := range primaries {
+				for _, r := range p.Slots {
+					if slot >= r[0] && slot < r[1] {
+						addr = p.Addr
+					}
+				}
+			}
+		}
+	}
+
+	if len(readTimeout) > 0 && o.conn.opt != nil {
+		c := o.conn
+		c.timeouts.read = readTimeout[0]
+		return buildConnFunc(c)("tcp", addr)
+	}
+	if o.connFunc != nil {
+		return o.connFunc("tcp", addr)
+	}
+	opts := []radix.DialOpt{radix.DialTimeout(o.timeout)}
+	if len(readTimeout) > 0 {
+		opts = append(opts, radix.DialReadTimeout(readTimeout[0]))
+	}
+	return radix.Dial("tcp", addr, opts...)
+}
+
+type nodeClient struct {
+	addr   string
+	client radix.Client
+}
+
+// primaryClients returns a client per primary for cluster, and the client of
+// the tag itself otherwise.
+func primaryClients(tag string) ([]nodeClient, error) {
+	client, err := getClientByTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	cc, ok := client.(*radix.Cluster)
+	if !ok {
+		return []nodeClient{{client: client}}, nil
+	}
+
+	var nodes []nodeClient
+	for _, p := range cc.Topo().Primaries() {
+		c, err := cc.Client(p.Addr)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, nodeClient{addr: p.Addr, client: c})
+	}
+	return nodes, nil
+}
+
+// commandTimeout returns min(config timeout, remaining time of ctx).
+func commandTimeout(ctx context.Context, tag string) time.Duration {
+	timeout := getOptionByTag(tag).timeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if remain := time.Until(deadline); remain < timeout {
+			timeout = remain
+		}
+	}
+	return timeout
+}
+
+// sendAction runs the action of a command helper. Only a ctx that can be
+// cancelled goes through doContext. Plain calls such as Do and Eval pass
+// context.Background() and are sent straight to the client, where only the
+// timeouts of the connection apply.
+func sendAction(ctx context.Context, client radix.Client, tag string, a radix.Action) error {
+	if ctx.Done() == nil {
+		return doGuarded(tag, a, client.Do)
+	}
+	return doContext(ctx, client, tag, a)
+}
+
+// doContext runs the action on a single connection and expires that
+// connection when the timeout derived from ctx fires. An expired connection
+// is closed so the pool discards it instead of reusing it, as is one whose
+// credentials the server rejected, like connGuard does.
+func doContext(ctx context.Context, client radix.Client, tag string, a radix.Action) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var key string
+	if keys := a.Keys(); len(keys) > 0 {
+		key = keys[0]
+	}
+	timeout := commandTimeout(ctx, tag)
+	opt := getOptionByTag(tag)
+	return skipStale(func() error {
+		return client.Do(clusterRetry{inner: a, Action: radix.WithConn(key, func(conn radix.Conn) error {
+			return runContext(ctx, opt, conn, a, timeout)
+		})})
+	})
+}
+
+// runContext runs a on conn for doContext.
+func runContext(ctx context.Context, opt *tagOption, conn radix.Conn, a radix.Action, timeout time.Duration) error {
+	defer opt.watchLeak()()
+	if err := opt.reauth(conn); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var expired bool
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		case <-done:
+			return
+		}
+		expired = true
+		expireConn(conn.NetConn())
+	}()
+
+	err := conn.Do(a)
+	close(done)
+	wg.Wait()
+
+	if ClassifyError(err) == KindAuth {
+		opt.rejected(conn)
+	} else if expired || isTimeoutErr(err) {
+		conn.Close()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+	}
+	return err
+}
+
+// clusterRetry lets the cluster follow MOVED and ASK for an action wrapping
+// inner, like radix.WithConn, whenever it would for inner itself.
+type clusterRetry struct {
+	radix.Action
+	inner radix.Action
+}
+
+func (r clusterRetry) ClusterCanRetry() bool {
+	ccra, ok := r.inner.(radix.ClusterCanRetryAction)
+	return ok && ccra.ClusterCanRetry()
+}
+
+// errStaleConn is returned, before the action was sent, when a connection
+// dialed with credentials the server has since rejected failed to reauth.
+var errStaleConn = errors.New("Connection dialed with rejected credentials")
+
+// skipStale calls do again for as long as it hit a stale connection. Each one
+// is closed on the way and new ones are dialed with the current credentials,
+// so this ends at the latest once the pool holds only new connections.
+func skipStale(do func() error) error {
+	for {
+		if err := do(); err != errStaleConn {
+			return err
+		}
+	}
+}
+
+// connGuard closes the connection after NOAUTH/WRONGPASS, so the pool
+// discards it and dials one that sends AUTH again, and has the other
+// connections dialed with the same credentials send AUTH again the next time
+// they come out of the pool. Timeouts and other network errors need nothing here, the pool
+// already closes those connections.
+type connGuard struct {
+	radix.Action
+	opt *tagOption
+}
+
+func (g connGuard) Run(conn radix.Conn) error {
+	defer g.opt.watchLeak()()
+	if err := g.opt.reauth(conn); err != nil {
+		return err
+	}
+
+	err := g.Action.Run(conn)
+	if ClassifyError(err) == KindAuth {
+		g.opt.rejected(conn)
+	}
+	return err
+}
+
+// ClusterCanRetry lets the cluster follow MOVED and ASK whenever it would for
+// the guarded action.
+func (g connGuard) ClusterCanRetry() bool {
+	return clusterRetry{inner: g.Action}.ClusterCanRetry()
+}
+
+// doGuarded runs a with do, e.g. client.Do. Single commands are sent as they
+// are since the pool only pipelines radix's own command type, unless the tag
+// has a password or a conn_leak_timeout, then they need connGuard to discard
+// connections with stale credentials and to watch the connection like any
+// other action. A command rejected with NOAUTH/WRONGPASS was not run and is
+// sent once more, on a connection dialed with the current credentials.
+func doGuarded(tag string, a radix.Action, do func(radix.Action) error) error {
+	opt := getOptionByTag(tag)
+	guarded := func() error { return do(connGuard{Action: a, opt: opt}) }
+	if _, ok := a.(radix.CmdAction); !ok {
+		return skipStale(guarded)
+	}
+	var err error
+	if _, password := opt.credentials(); len(password) > 0 || opt.leakTimeout > 0 {
+		err = skipStale(guarded)
+	} else {
+		err = do(a)
+	}
+	if ClassifyError(err) == KindAuth {
+		err = skipStale(guarded)
+	}
+	return err
+}
+
+// runClean runs fn on a pooled connection and, when fn panics, resets the
+// connection before the pool gets it back, so it can't be left inside MULTI
+// or in subscriber mode.
+func runClean(tag string, conn radix.Conn, fn func(conn radix.Conn) error) error {
+	defer func() {
+		if r := recover(); r != nil {
+			resetConn(tag, conn)
+			panic(r)
+		}
+	}()
+	return fn(conn)
+}
+
+// resetConn brings conn back to the state of a new connection. With the
+// reset_conn option and Redis 6.2 it sends RESET, then AUTH, the CLIENT flags
+// and READONLY again since RESET drops them; otherwise, or when that fails, the
+// connection is closed and the pool dials a new one.
+func resetConn(tag string, conn radix.Conn) {
+	opt := getOptionByTag(tag)
+	if opt.resetConn && requireVersion(tag, "6.2.0") == nil {
+		err := conn.Do(radix.Cmd(nil, "RESET"))
+		if err == nil {
+			err = authConn(conn, opt)
+		}
+		if err == nil {
+			err = selectDB(conn, opt.conn.db)
+		}
+		if err == nil {
+			err = setClientFlags(conn, conn.NetConn().RemoteAddr().String(), opt.conn.noEvict, opt.conn.noTouch)
+		}
+		if err == nil && opt.conn.readOnly {
+			err = setReadOnly(conn, conn.NetConn().RemoteAddr().String())
+		}
+		if err == nil {
+			return
+		}
+		logWarn("redis.RESET tag:%s err:%v, closing connection", tag, err)
+	}
+	conn.Close()
 }
 
 func GetRadixClient(tag string) (radix.Client, error) {
 	return getClientByTag(tag)
 }
 
-func Do(rcv interface{}, tag, cmd, key string, args ...interface{}) error {
+// WithConn runs fn on one connection borrowed from the pool of tag, for
+// command sequences that need the same connection (WATCH, CLIENT state)
+// without MULTI. The connection goes back to the pool afterwards, unless fn
+// failed other than with an error reply, then it is closed. On cluster a
+// random node is used, see WithKeyConn.
+func WithConn(tag string, fn func(conn radix.Conn) error) error {
+	return WithKeyConn(tag, "", fn)
+}
+
+// WithKeyConn is WithConn on the node owning key on cluster.
+func WithKeyConn(tag, key string, fn func(conn radix.Conn) error) error {
+	t := time.Now()
+	client, err := getClientByTag(tag)
+	if err != nil {
+		return err
+	}
+
+	opt := getOptionByTag(tag)
+	err = doGuarded(tag, radix.WithConn(key, func(conn radix.Conn) error {
+		err := runClean(tag, conn, fn)
+		if err != nil && !isReplyErr(err) {
+			conn.Close()
+		} else if opt.resetConn {
+			resetConn(tag, conn)
+		}
+		return err
+	}), client.Do)
+	logInfo("redis.WithConn cost:%v tag:%s key:%s err:%v", time.Since(t), tag, key, err)
+	return err
+}
+
+func Do(rcv interface{}, tag, cmd, key string, args ...interface{}) (err error) {
+	cmd = normalizeCmd(cmd)
+	if !instrumented() {
+		return doFlatContext(context.Background(), rcv, tag, cmd, key, args)
+	}
 	t := time.Now()
 	defer func() {
 		t2 := time.Since(t)
 		r := reflect.ValueOf(rcv).Elem()
 		logInfo("redis.Do cost:%v tag:%s cmd:%s key:%s rcv:%#v", t2, tag, cmd, key, r)
+		recordCommand(tag, cmd, key, t2, err)
+	}()
+	return doFlatContext(context.Background(), rcv, tag, cmd, key, args)
+}
+
+func DoContext(ctx context.Context, rcv interface{}, tag, cmd, key string, args ...interface{}) (err error) {
+	cmd = normalizeCmd(cmd)
+	if !instrumented() {
+		return doFlatContext(ctx, rcv, tag, cmd, key, args)
+	}
+	t := time.Now()
+	defer func() {
+		t2 := time.Since(t)
+		r := reflect.ValueOf(rcv).Elem()
+		logInfo("redis.DoContext cost:%v tag:%s cmd:%s key:%s rcv:%#v", t2, tag, cmd, key, r)
+		recordCommand(tag, cmd, key, t2, err)
 	}()
+	return doFlatContext(ctx, rcv, tag, cmd, key, args)
+}
+
+func doFlatContext(ctx context.Context, rcv interface{}, tag, cmd, key string, args []interface{}) error {
+	if err := validateFlatArgs(cmd, args); err != nil {
+		return err
+	}
+	if err := checkCommand(tag, cmd); err != nil {
+		return err
+	}
 
 	client, err := getClientByTag(tag)
 	if err == nil {
-		return client.Do(radix.FlatCmd(rcv, cmd, key, args...))
+		var a radix.Action
+		if a, err = withFlatDefaultTTL(tag, cmd, key, args, radix.FlatCmd(codecReceiver(rcv), cmd, key, args...)); err != nil {
+			return err
+		}
+		err = sendAction(ctx, client, tag, a)
+		invalidateCached(tag, cmd, append([]string{key}, flatArgStrings(args)...))
+		return wrapErr(tag, cmd, err)
 	}
 	return err
 }
 
-func DoCmd(rcv interface{}, tag, cmd string, args ...string) error {
+func DoCmd(rcv interface{}, tag, cmd string, args ...string) (err error) {
+	cmd = normalizeCmd(cmd)
+	if !instrumented() {
+		return doCmdContext(context.Background(), rcv, tag, cmd, args)
+	}
 	t := time.Now()
 	defer func() {
 		t2 := time.Since(t)
 		r := reflect.ValueOf(rcv).Elem()
 		logInfo("redis.DoCmd cost:%v tag:%s cmd:%s rcv:%#v", t2, tag, cmd, r)
+		recordCmdArgs(tag, cmd, args, t2, err)
 	}()
+	return doCmdContext(context.Background(), rcv, tag, cmd, args)
+}
+
+// DoCmdContext is DoCmd that gives up once ctx is done, like DoContext.
+func DoCmdContext(ctx context.Context, rcv interface{}, tag, cmd string, args ...string) (err error) {
+	cmd = normalizeCmd(cmd)
+	if !instrumented() {
+		return doCmdContext(ctx, rcv, tag, cmd, args)
+	}
+	t := time.Now()
+	defer func() {
+		t2 := time.Since(t)
+		r := reflect.ValueOf(rcv).Elem()
+		logInfo("redis.DoCmdContext cost:%v tag:%s cmd:%s rcv:%#v", t2, tag, cmd, r)
+		recordCmdArgs(tag, cmd, args, t2, err)
+	}()
+	return doCmdContext(ctx, rcv, tag, cmd, args)
+}
+
+func doCmdContext(ctx context.Context, rcv interface{}, tag, cmd string, args []string) error {
+	if err := validateArgs(cmd, len(args)); err != nil {
+		return err
+	}
+	if err := checkCommand(tag, cmd); err != nil {
+		return err
+	}
 
 	client, err := getClientByTag(tag)
 	if err == nil {
-		return client.Do(radix.Cmd(rcv, cmd, args...))
+		a := radix.Cmd(codecReceiver(rcv), cmd, args...)
+		var send radix.Action = a
+		if keys := a.Keys(); len(keys) == 1 && len(args) > 0 {
+			if send, err = withDefaultTTL(tag, cmd, keys[0], args[1:], a); err != nil {
+				return err
+			}
+		}
+		err = sendAction(ctx, client, tag, send)
+		invalidateCached(tag, cmd, args)
+		return wrapErr(tag, cmd, err)
 	}
 	return err
 }
 
-func Eval(rcv interface{}, tag, script string, numKeys int, args ...string) error {
+func Eval(rcv interface{}, tag, script string, numKeys int, args ...string) (err error) {
 	t := time.Now()
 	defer func() {
 		t2 := time.Since(t)
 		r := reflect.ValueOf(rcv).Elem()
 		logInfo("redis.Eval cost:%v tag:%s script:%s rcv:%#v", t2, tag, script, r)
+		recordCommand(tag, "EVAL", scriptKey(numKeys, args), t2, err)
+	}()
+	return evalContext(context.Background(), rcv, tag, script, numKeys, args)
+}
+
+// EvalContext is Eval that gives up once ctx is done, like DoContext.
+func EvalContext(ctx context.Context, rcv interface{}, tag, script string, numKeys int, args ...string) (err error) {
+	t := time.Now()
+	defer func() {
+		t2 := time.Since(t)
+		r := reflect.ValueOf(rcv).Elem()
+		logInfo("redis.EvalContext cost:%v tag:%s script:%s rcv:%#v", t2, tag, script, r)
+		recordCommand(tag, "EVAL", scriptKey(numKeys, args), t2, err)
 	}()
+	return evalContext(ctx, rcv, tag, script, numKeys, args)
+}
+
+func evalContext(ctx context.Context, rcv interface{}, tag, script string, numKeys int, args []string) error {
+	if err := checkCommand(tag, "EVAL"); err != nil {
+		return err
+	}
 
 	client, err := getClientByTag(tag)
 	if err == nil {
 		var s = radix.NewEvalScript(numKeys, script)
-		return client.Do(s.Cmd(rcv, args...))
+		a := s.Cmd(rcv, args...)
+		err = sendAction(ctx, client, tag, a)
+		invalidateCached(tag, "EVAL", a.Keys())
+		return wrapErr(tag, "EVAL", err)
 	}
 	return err
 }
 
-func EvalSmart(rcv interface{}, tag string, script *LuaScript, numKeys int, args ...string) error {
+func EvalSmart(rcv interface{}, tag string, script *LuaScript, numKeys int, args ...string) (err error) {
 	t := time.Now()
 	defer func() {
 		t2 := time.Since(t)
 		r := reflect.ValueOf(rcv).Elem()
 		logInfo("redis.EvalSmart cost:%v tag:%s lua_sha:%s rcv:%#v", t2, tag, script.SHA, r)
+		recordCommand(tag, "EVALSHA", scriptKey(numKeys, args), t2, err)
 	}()
+	return evalSmartContext(context.Background(), rcv, tag, script, numKeys, args)
+}
+
+// EvalSmartContext is EvalSmart that gives up once ctx is done, like
+// DoContext.
+func EvalSmartContext(ctx context.Context, rcv interface{}, tag string, script *LuaScript, numKeys int, args ...string) (err error) {
+	t := time.Now()
+	defer func() {
+		t2 := time.Since(t)
+		r := reflect.ValueOf(rcv).Elem()
+		logInfo("redis.EvalSmartContext cost:%v tag:%s lua_sha:%s rcv:%#v", t2, tag, script.SHA, r)
+		recordCommand(tag, "EVALSHA", scriptKey(numKeys, args), t2, err)
+	}()
+	return evalSmartContext(ctx, rcv, tag, script, numKeys, args)
+}
+
+func evalSmartContext(ctx context.Context, rcv interface{}, tag string, script *LuaScript, numKeys int, args []string) error {
+	if err := checkCommand(tag, "EVALSHA"); err != nil {
+		return err
+	}
 
 	client, err := getClientByTag(tag)
 	if err != nil {
@@ -349,16 +1651,47 @@ func EvalSmart(rcv interface{}, tag string, script *LuaScript, numKeys int, args
 	}
 
 	if len(script.SHA) == 0 {
+		// SCRIPT LOAD runs nothing, but it puts the Lua body on the server
+		// just like EVAL would
+		for _, cmd := range []string{"SCRIPT", "EVAL"} {
+			if err := checkCommand(tag, cmd); err != nil {
+				return err
+			}
+		}
 		var ret string
-		err = client.Do(radix.Cmd(&ret, "SCRIPT", "LOAD", script.Script))
+		err = sendAction(ctx, client, tag, radix.Cmd(&ret, "SCRIPT", "LOAD", script.Script))
 		if err != nil {
-			return err
+			return wrapErr(tag, "SCRIPT LOAD", err)
 		}
 		script.SHA = ret
 	}
 
-	var realArgs = make([]string, 0, len(args)+2)
-	realArgs = append(realArgs, script.SHA, strconv.FormatInt(int64(numKeys), 10))
-	realArgs = append(realArgs, args...)
-	return client.Do(radix.Cmd(rcv, "EVALSHA", realArgs...))
+	evalArgs := func(first string) []string {
+		var realArgs = make([]string, 0, len(args)+2)
+		realArgs = append(realArgs, first, strconv.FormatInt(int64(numKeys), 10))
+		return append(realArgs, args...)
+	}
+	var keys []string
+	if numKeys >= 0 && numKeys <= len(args) {
+		keys = args[:numKeys]
+	}
+
+	cmd := "EVALSHA"
+	err = sendAction(ctx, client, tag, radix.Cmd(rcv, cmd, evalArgs(script.SHA)...))
+	switch ClassifyError(err) {
+	case KindNoScript:
+		// the node lost its script cache (restart, failover, SCRIPT FLUSH),
+		// EVAL sends the body along and caches it again
+		cmd = "EVAL"
+		if err = checkCommand(tag, cmd); err != nil {
+			return err
+		}
+		err = sendAction(ctx, client, tag, radix.Cmd(rcv, cmd, evalArgs(script.Script)...))
+	case KindBusy:
+		if atomic.LoadInt32(&scriptKillOnBusy) == 1 && killScript(client, tag, keys) {
+			err = sendAction(ctx, client, tag, radix.Cmd(rcv, cmd, evalArgs(script.SHA)...))
+		}
+	}
+	invalidateCached(tag, cmd, keys)
+	return wrapErr(tag, cmd, err)
 }
@@ -0,0 +1,487 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func TestMain(m *testing.M) {
+	quiet := func(format string, a ...interface{}) {}
+	SetLogInfoFunc(quiet)
+	logWarn = quiet
+	os.Exit(m.Run())
+}
+
+// newTestTag starts a fakeRedis and registers it as a standalone tag named
+// after the test, both are closed when the test ends. cfg can adjust the
+// config before init.
+func newTestTag(t testing.TB, cfg ...func(c *StandaloneConfig)) (*fakeRedis, string) {
+	t.Helper()
+
+	s := newFakeRedis(t, nil)
+	c := StandaloneConfig{Tag: t.Name(), Addr: s.addr()}
+	for _, f := range cfg {
+		f(&c)
+	}
+	if err := InitRedisStandalone([]StandaloneConfig{c}); err != nil {
+		t.Fatalf("init tag [%s]: %v", c.Tag, err)
+	}
+	t.Cleanup(func() { Close(c.Tag) })
+	return s, c.Tag
+}
+
+// newTestCluster is newTestTag for a cluster tag, fakeRedis answers CLUSTER
+// SLOTS as a single node owning all slots.
+func newTestCluster(t *testing.T) (*fakeRedis, string) {
+	t.Helper()
+
+	s := newFakeRedis(t, nil)
+	tag := t.Name()
+	if err := InitRedisCluster([]ClusterConfig{{Tag: tag, Addrs: []string{s.addr()}}}); err != nil {
+		t.Fatalf("init cluster tag [%s]: %v", tag, err)
+	}
+	t.Cleanup(func() { Close(tag) })
+	return s, tag
+}
+
+// realRedis returns the address of a Redis server from REDIS_ADDR, for the
+// few tests fakeRedis can't serve, and skips the test without it.
+func realRedis(t *testing.T) string {
+	addr := os.Getenv("REDIS_ADDR")
+	if len(addr) == 0 {
+		t.Skip("REDIS_ADDR not set")
+	}
+	return addr
+}
+
+// realCluster returns the comma separated node addresses of a Redis cluster
+// from REDIS_CLUSTER_ADDRS, and skips the test without them.
+func realCluster(t *testing.T) []string {
+	addrs := os.Getenv("REDIS_CLUSTER_ADDRS")
+	if len(addrs) == 0 {
+		t.Skip("REDIS_CLUSTER_ADDRS not set")
+	}
+	return strings.Split(addrs, ",")
+}
+
+// recordClient keeps the actions given to Do without running them.
+type recordClient struct {
+	radix.Client
+	actions []radix.Action
+}
+
+func (c *recordClient) Do(a radix.Action) error {
+	c.actions = append(c.actions, a)
+	return nil
+}
+
+func TestDoContextFollowsRedirects(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &recordClient{}
+	if err := doContext(ctx, client, "redirect", radix.Cmd(nil, "GET", "k")); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.actions) != 1 {
+		t.Fatalf("got %d actions, want 1", len(client.actions))
+	}
+	a, ok := client.actions[0].(radix.ClusterCanRetryAction)
+	if !ok || !a.ClusterCanRetry() {
+		t.Fatalf("action %T is not retried by the cluster", client.actions[0])
+	}
+	if keys := client.actions[0].Keys(); len(keys) != 1 || keys[0] != "k" {
+		t.Fatalf("got keys %v, want [k]", keys)
+	}
+}
+
+func TestDoContextCluster(t *testing.T) {
+	addrs := realCluster(t)
+	tag := t.Name()
+	if err := InitRedisCluster([]ClusterConfig{{Tag: tag, Addrs: addrs}}); err != nil {
+		t.Fatal(err)
+	}
+	defer Close(tag)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, key := range []string{"{a}k", "{b}k", "{c}k", "{d}k"} {
+		var ok string
+		if err := DoContext(ctx, &ok, tag, "SET", key, "v"); err != nil {
+			t.Fatalf("SET %s: %v", key, err)
+		}
+		var v string
+		if err := DoCmdContext(ctx, &v, tag, "GET", key); err != nil || v != "v" {
+			t.Fatalf("GET %s: %q %v", key, v, err)
+		}
+	}
+}
+
+func TestSendActionPlain(t *testing.T) {
+	client := &recordClient{}
+	cmd := radix.Cmd(nil, "GET", "k")
+	if err := sendAction(context.Background(), client, "plain", cmd); err != nil {
+		t.Fatal(err)
+	}
+	// not wrapped in the WithConn of doContext
+	if client.actions[0] != cmd {
+		t.Fatalf("command sent as %T, want it unwrapped", client.actions[0])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := sendAction(ctx, client, "plain", cmd); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.actions[1].(clusterRetry); !ok {
+		t.Fatalf("command with a ctx sent as %T, want doContext's", client.actions[1])
+	}
+}
+
+func TestDoGuardedKeepsCommands(t *testing.T) {
+	client := &recordClient{}
+	cmd := radix.Cmd(nil, "GET", "k")
+	if err := doGuarded("guard", cmd, client.Do); err != nil {
+		t.Fatal(err)
+	}
+	// the pool only pipelines radix's own command type
+	if client.actions[0] != cmd {
+		t.Fatalf("command sent as %T, want it unwrapped", client.actions[0])
+	}
+
+	if err := doGuarded("guard", radix.WithConn("k", nil), client.Do); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.actions[1].(connGuard); !ok {
+		t.Fatalf("WithConn sent as %T, want a connGuard", client.actions[1])
+	}
+	if g := (connGuard{Action: cmd}); !g.ClusterCanRetry() {
+		t.Fatal("guarded command is not retried by the cluster")
+	}
+}
+
+func TestConnLeakTimeout(t *testing.T) {
+	_, tag := newTestTag(t, func(c *StandaloneConfig) { c.ConnLeakTimeout = 50 })
+
+	var mu sync.Mutex
+	var warnings []string
+	logWarn = func(format string, a ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		warnings = append(warnings, fmt.Sprintf(format, a...))
+	}
+	defer func() { logWarn = func(format string, a ...interface{}) {} }()
+
+	done := make(chan error)
+	go func() {
+		var v []string
+		done <- Do(&v, tag, "BLPOP", "leak:q", "1")
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	s, err := PoolStats(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.LeakedConns != 1 {
+		t.Fatalf("got %d leaked connections while BLPOP blocks, want 1", s.LeakedConns)
+	}
+	mu.Lock()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "held over") {
+		t.Fatalf("got warnings %q, want one about the held connection", warnings)
+	}
+	mu.Unlock()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := PoolStats(tag); s.LeakedConns != 0 {
+		t.Fatalf("got %d leaked connections after BLPOP returned, want 0", s.LeakedConns)
+	}
+}
+
+func TestSetPasswordRotation(t *testing.T) {
+	s, tag := newTestTag(t, func(c *StandaloneConfig) { c.PoolSize = 4 })
+	for i := 0; i < 50; i++ {
+		if st, _ := PoolStats(tag); st.AvailConns == 4 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the pooled connections never sent AUTH and get NOAUTH from now on
+	s.requireAuth("", "rotated")
+	if err := SetPassword(tag, "rotated"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		var ok string
+		if err := Do(&ok, tag, "SET", "rotate:k", "v"); err != nil && i > 0 {
+			t.Fatalf("call %d after the rotation failed: %v", i, err)
+		}
+	}
+}
+
+func TestTimeoutDiscardsConn(t *testing.T) {
+	addr := realRedis(t)
+	tag := t.Name()
+	err := InitRedisStandalone([]StandaloneConfig{{Tag: tag, Addr: addr, PoolSize: 1, ReadTimeout: 100}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(tag)
+
+	var ok string
+	if err := Do(&ok, tag, "SET", "timeout:k", "v"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DoCmd(&ok, tag, "DEBUG", "SLEEP", "0.3"); !isTimeoutErr(err) {
+		t.Fatalf("DEBUG SLEEP got %v, want a timeout", err)
+	}
+	// the OK of DEBUG SLEEP must not be read as the reply of GET
+	time.Sleep(300 * time.Millisecond)
+	var v string
+	if err := Do(&v, tag, "GET", "timeout:k"); err != nil || v != "v" {
+		t.Fatalf("GET after timeout got %q %v, want v", v, err)
+	}
+}
+
+func TestDeadlineConnExpire(t *testing.T) {
+	c, peer := net.Pipe()
+	defer peer.Close()
+	dc := &deadlineConn{Conn: c, read: time.Minute, write: time.Minute}
+	defer dc.Close()
+
+	// an expire ahead of the call must not be undone by the deadline the
+	// call sets for itself
+	expireConn(&genConn{Conn: dc})
+	within(t, time.Second, func() {
+		if _, err := dc.Read(make([]byte, 1)); !isTimeoutErr(err) {
+			t.Errorf("Read got %v, want a timeout", err)
+		}
+		if _, err := dc.Write([]byte("x")); !isTimeoutErr(err) {
+			t.Errorf("Write got %v, want a timeout", err)
+		}
+	})
+}
+
+// holdConn checks out a connection of tag until the returned func is called.
+func holdConn(t *testing.T, tag string) func() {
+	t.Helper()
+	held := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		WithConn(tag, func(conn radix.Conn) error {
+			close(held)
+			<-release
+			return nil
+		})
+	}()
+	<-held
+	return func() {
+		close(release)
+		<-done
+	}
+}
+
+func ping(tag string) error {
+	return WithConn(tag, func(conn radix.Conn) error {
+		return conn.Do(radix.Cmd(nil, "PING"))
+	})
+}
+
+func TestPoolOverflowError(t *testing.T) {
+	_, tag := newTestTag(t, func(c *StandaloneConfig) {
+		c.PoolSize = 1
+		c.PoolOverflow = PoolOverflowError
+	})
+	release := holdConn(t, tag)
+	defer release()
+
+	if err := ping(tag); !errors.Is(err, ErrPoolExhausted) {
+		t.Fatalf("got %v, want ErrPoolExhausted", err)
+	}
+}
+
+func TestPoolOverflowBlock(t *testing.T) {
+	_, tag := newTestTag(t, func(c *StandaloneConfig) {
+		c.PoolSize = 1
+		c.PoolOverflow = PoolOverflowBlock
+	})
+	release := holdConn(t, tag)
+
+	done := make(chan error, 1)
+	go func() { done <- ping(tag) }()
+	select {
+	case err := <-done:
+		release()
+		t.Fatalf("got %v while the pool was empty, want to wait", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoolOverflowBurst(t *testing.T) {
+	_, tag := newTestTag(t, func(c *StandaloneConfig) {
+		c.PoolSize = 1
+		c.PoolOverflow = PoolOverflowBurst
+	})
+	release := holdConn(t, tag)
+
+	if err := ping(tag); err != nil {
+		release()
+		t.Fatal(err)
+	}
+	release()
+
+	// the burst connection is closed once given back to the full pool
+	s, err := PoolStats(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.AvailConns != 1 {
+		t.Fatalf("got %d idle conns, want 1", s.AvailConns)
+	}
+}
+
+func TestPoolOverflowUnknown(t *testing.T) {
+	err := InitRedisStandalone([]StandaloneConfig{{Tag: t.Name(), Addr: "127.0.0.1:0", PoolOverflow: "drop"}})
+	if err == nil {
+		Close(t.Name())
+		t.Fatal("init with an unknown pool_overflow succeeded")
+	}
+}
+
+func TestInitWithTwice(t *testing.T) {
+	s := newFakeRedis(t, nil)
+
+	tag := t.Name()
+	conf := filepath.Join(t.TempDir(), "server.json")
+	raw := fmt.Sprintf(`{"redis-standalone":[{"tag":%q,"addr":%q,"pool_size":3}]}`, tag, s.addr())
+	if err := ioutil.WriteFile(conf, []byte(raw), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := InitWith(conf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer Close(tag)
+
+	st, err := PoolStats(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.AvailConns != 3 {
+		t.Fatalf("got %d idle conns, want 3", st.AvailConns)
+	}
+	// the first pool's connections are closed by the second init
+	deadline := time.Now().Add(time.Second)
+	for s.connCount() != st.AvailConns {
+		if time.Now().After(deadline) {
+			t.Fatalf("server has %d conns, want %d", s.connCount(), st.AvailConns)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func BenchmarkDo(b *testing.B) {
+	_, tag := newTestTag(b)
+	var ok string
+	if err := Do(&ok, tag, "SET", "bench:k", "v"); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, off := range []int32{0, 1} {
+		name := "instrumented"
+		if off == 1 {
+			name = "uninstrumented"
+		}
+		b.Run(name, func(b *testing.B) {
+			atomic.StoreInt32(&instrumentationOff, off)
+			defer atomic.StoreInt32(&instrumentationOff, 0)
+
+			var v string
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := Do(&v, tag, "GET", "bench:k"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestInitClusterUnreachable(t *testing.T) {
+	s := newFakeRedis(t, nil)
+
+	ok, bad := t.Name()+"/ok", t.Name()+"/bad"
+	err := InitRedisCluster([]ClusterConfig{
+		{Tag: ok, Addrs: []string{s.addr()}},
+		{Tag: bad, Addrs: []string{"127.0.0.1:1"}, ConnectTimeout: 100},
+	})
+	if err == nil {
+		Close(ok)
+		Close(bad)
+		t.Fatal("init with an unreachable cluster succeeded")
+	}
+	// all tags or none are registered
+	for _, tag := range []string{ok, bad} {
+		if _, err := GetRadixClient(tag); !errors.Is(err, ErrTagNotFound) {
+			t.Errorf("tag [%s] got %v, want ErrTagNotFound", tag, err)
+		}
+	}
+}
+
+func TestInitWithRedactsSecrets(t *testing.T) {
+	s := newFakeRedis(t, nil)
+	s.requireAuth("", "redis-secret")
+	proxy := newSocks5Server(t, "u", "proxy-secret")
+
+	var mu sync.Mutex
+	var logs []string
+	SetLogInfoFunc(func(format string, a ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		logs = append(logs, fmt.Sprintf(format, a...))
+	})
+	defer SetLogInfoFunc(func(format string, a ...interface{}) {})
+
+	tag := t.Name()
+	conf := filepath.Join(t.TempDir(), "server.json")
+	raw := fmt.Sprintf(`{"redis-standalone":[{"tag":%q,"addr":%q,"password":"redis-secret",`+
+		`"socks5":{"user":"u","pass":"proxy-secret","addr":%q}}]}`, tag, s.addr(), proxy.addr())
+	if err := ioutil.WriteFile(conf, []byte(raw), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitWith(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer Close(tag)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, l := range logs {
+		if strings.Contains(l, "redis-secret") || strings.Contains(l, "proxy-secret") {
+			t.Fatalf("secret logged: %s", l)
+		}
+	}
+}
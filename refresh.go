@@ -0,0 +1,95 @@
+package redis
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+// sentinelSource is what Refresh needs to ask the sentinels for the master
+// of a tag and to build a new client for it.
+type sentinelSource struct {
+	name     string
+	connFunc radix.ConnFunc
+	dial     func() (*radix.Sentinel, error)
+}
+
+// Refresh makes tag follow a topology change right away instead of on the
+// next failed command. On cluster the slot map is synced again. On sentinel
+// the sentinels are asked for the current master and, when it moved, the
+// client is replaced by one connected to the new master. Standalone tags
+// have nothing to refresh.
+func Refresh(tag string) (err error) {
+	t := time.Now()
+	defer func() {
+		logInfo("redis.Refresh cost:%v tag:%s err:%v", time.Since(t), tag, err)
+	}()
+
+	client, err := getClientByTag(tag)
+	if err != nil {
+		return err
+	}
+	switch cc := client.(type) {
+	case *radix.Cluster:
+		return wrapErr(tag, "CLUSTER SLOTS", cc.Sync())
+	case *radix.Sentinel:
+		return refreshSentinel(tag, cc)
+	}
+	return nil
+}
+
+func refreshSentinel(tag string, cc *radix.Sentinel) error {
+	opt := getOptionByTag(tag)
+	src := opt.sentinel
+	if src == nil {
+		return nil
+	}
+
+	master, err := src.queryMaster(cc.SentinelAddrs())
+	if err != nil {
+		return err
+	}
+	if cur, _ := cc.Addrs(); cur == master {
+		return nil
+	}
+
+	client, err := src.dial()
+	if err != nil {
+		return err
+	}
+	cur, _ := cc.Addrs()
+	logWarn("redis.Refresh tag:%s master moved from %s to %s", tag, cur, master)
+	registerClient(tag, client, opt)
+	return nil
+}
+
+// queryMaster returns the master address reported by the first sentinel that
+// answers.
+func (s *sentinelSource) queryMaster(addrs []string) (string, error) {
+	errs := NodeErrors{}
+	for _, addr := range addrs {
+		conn, err := s.connFunc("tcp", addr)
+		if err != nil {
+			errs[addr] = err
+			continue
+		}
+		var reply []string
+		err = conn.Do(radix.Cmd(&reply, "SENTINEL", "GET-MASTER-ADDR-BY-NAME", s.name))
+		conn.Close()
+		if err != nil {
+			errs[addr] = err
+			continue
+		}
+		if len(reply) != 2 {
+			errs[addr] = fmt.Errorf("Sentinel knows no master [%s]", s.name)
+			continue
+		}
+		return net.JoinHostPort(reply[0], reply[1]), nil
+	}
+	if len(errs) == 0 {
+		return "", fmt.Errorf("No sentinel to ask for master [%s]", s.name)
+	}
+	return "", errs
+}
@@ -0,0 +1,85 @@
+package redis
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+// DoReplica is Do for read commands that may be served by a replica, on
+// sentinel and cluster tags a random secondary gets the command. With
+// replica_fallback a replica that can't be reached or times out is skipped
+// and the command retried on the primary, error replies like WRONGTYPE are
+// returned as they are. Standalone tags always use the primary.
+func DoReplica(rcv interface{}, tag, cmd, key string, args ...interface{}) (err error) {
+	cmd = normalizeCmd(cmd)
+	t := time.Now()
+	fallback := false
+	defer func() {
+		t2 := time.Since(t)
+		r := reflect.ValueOf(rcv).Elem()
+		logInfo("redis.DoReplica cost:%v tag:%s cmd:%s key:%s fallback:%v rcv:%#v", t2, tag, cmd, key, fallback, r)
+		recordCommand(tag, cmd, key, t2, err)
+	}()
+
+	fallback, err = doSecondary(rcv, tag, cmd, key, args, false, getOptionByTag(tag).replicaFallback)
+	return err
+}
+
+// DoReadOnly sends a single read command to a replica of the key's slot on
+// cluster tags, leaving all other calls on the primaries. The primary serves
+// it when the slot has no replica or the replica can't be reached. Other tag
+// types always use the primary.
+func DoReadOnly(rcv interface{}, tag, cmd, key string, args ...interface{}) (err error) {
+	cmd = normalizeCmd(cmd)
+	t := time.Now()
+	fallback := false
+	defer func() {
+		t2 := time.Since(t)
+		r := reflect.ValueOf(rcv).Elem()
+		logInfo("redis.DoReadOnly cost:%v tag:%s cmd:%s key:%s fallback:%v rcv:%#v", t2, tag, cmd, key, fallback, r)
+		recordCommand(tag, cmd, key, t2, err)
+	}()
+
+	fallback, err = doSecondary(rcv, tag, cmd, key, args, true, true)
+	return err
+}
+
+// doSecondary runs the command on a secondary, retrying on the primary when
+// fallback is set and the secondary failed on the connection. With
+// clusterOnly sentinel tags use their primary too.
+func doSecondary(rcv interface{}, tag, cmd, key string, args []interface{}, clusterOnly, fallback bool) (bool, error) {
+	if err := validateFlatArgs(cmd, args); err != nil {
+		return false, err
+	}
+	if err := checkCommand(tag, cmd); err != nil {
+		return false, err
+	}
+
+	client, err := getClientByTag(tag)
+	if err != nil {
+		return false, err
+	}
+
+	a := radix.FlatCmd(rcv, cmd, key, args...)
+	switch cc := client.(type) {
+	case *radix.Sentinel:
+		if clusterOnly {
+			return false, wrapErr(tag, cmd, doGuarded(tag, a, client.Do))
+		}
+		err = doGuarded(tag, a, cc.DoSecondary)
+	case *radix.Cluster:
+		// radix picks the primary itself when the slot has no secondary
+		err = doGuarded(tag, a, cc.DoSecondary)
+	default:
+		return false, wrapErr(tag, cmd, doGuarded(tag, a, client.Do))
+	}
+
+	if err != nil && !isReplyErr(err) && fallback {
+		logWarn("redis.DoSecondary tag:%s cmd:%s key:%s replica err:%v, retry on primary", tag, cmd, key, err)
+		err = doGuarded(tag, radix.FlatCmd(rcv, cmd, key, args...), client.Do)
+		return true, wrapErr(tag, cmd, err)
+	}
+	return false, wrapErr(tag, cmd, err)
+}
@@ -0,0 +1,50 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func TestDoReadOnlyCluster(t *testing.T) {
+	s, tag := newTestCluster(t)
+	s.set("ro:k", "v")
+
+	var v string
+	if err := DoReadOnly(&v, tag, "GET", "ro:k"); err != nil || v != "v" {
+		t.Fatalf("DoReadOnly got %q %v, want v", v, err)
+	}
+	v = ""
+	if err := DoReplica(&v, tag, "GET", "ro:k"); err != nil || v != "v" {
+		t.Fatalf("DoReplica got %q %v, want v", v, err)
+	}
+}
+
+// TestDoReadOnlyReplicas needs a cluster whose primaries have replicas, the
+// replicas answer MOVED unless the connection sent READONLY.
+func TestDoReadOnlyReplicas(t *testing.T) {
+	addrs := realCluster(t)
+	tag := t.Name()
+	if err := InitRedisCluster([]ClusterConfig{{Tag: tag, Addrs: addrs}}); err != nil {
+		t.Fatal(err)
+	}
+	defer Close(tag)
+
+	for _, key := range []string{"{a}ro", "{b}ro", "{c}ro", "{d}ro"} {
+		var ok string
+		if err := Do(&ok, tag, "SET", key, "v"); err != nil {
+			t.Fatal(err)
+		}
+		// let the replicas of the key's primary catch up
+		err := WithKeyConn(tag, key, func(conn radix.Conn) error {
+			return conn.Do(radix.Cmd(nil, "WAIT", "1", "1000"))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v string
+		if err := DoReadOnly(&v, tag, "GET", key); err != nil || v != "v" {
+			t.Fatalf("DoReadOnly %s got %q %v, want v", key, v, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+// The helpers tell a missing key from an empty value the same way: getters of
+// a single value return found == false on a nil reply and found == true for
+// an empty string, collection getters return an empty, never nil, slice or
+// map since Redis doesn't keep empty collections.
+
+// doMaybe is Do for commands answering nil when there is nothing, e.g. a
+// missing key. found is false then and rcv is left untouched.
+func doMaybe(rcv interface{}, tag, cmd, key string, args ...interface{}) (found bool, err error) {
+	mn := radix.MaybeNil{Rcv: rcv}
+	if err := Do(&mn, tag, cmd, key, args...); err != nil {
+		return false, err
+	}
+	return !mn.Nil, nil
+}
+
+// helpers for walking replies decoded into interface{}, where simple strings
+// come back as string, bulk strings as []byte, integers as int64 and arrays
+// as []interface{}
+
+func replyString(v interface{}) (string, error) {
+	switch vv := v.(type) {
+	case string:
+		return vv, nil
+	case []byte:
+		return string(vv), nil
+	case int64:
+		return strconv.FormatInt(vv, 10), nil
+	case nil:
+		return "", nil
+	}
+	return "", fmt.Errorf("Unexpected reply type %T", v)
+}
+
+func replyInt(v interface{}) (int64, error) {
+	switch vv := v.(type) {
+	case int64:
+		return vv, nil
+	case string:
+		return strconv.ParseInt(vv, 10, 64)
+	case []byte:
+		return strconv.ParseInt(string(vv), 10, 64)
+	}
+	return 0, fmt.Errorf("Unexpected reply type %T", v)
+}
+
+func replyArray(v interface{}) ([]interface{}, error) {
+	switch vv := v.(type) {
+	case []interface{}:
+		return vv, nil
+	case nil:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("Unexpected reply type %T", v)
+}
+
+func replyStrings(v interface{}) ([]string, error) {
+	arr, err := replyArray(v)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, len(arr))
+	for _, e := range arr {
+		s, err := replyString(e)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, s)
+	}
+	return ret, nil
+}
+
+// DoTree runs a command and returns its reply undecoded, for nested replies
+// (XRANGE, GEOSEARCH WITHCOORD, ...) there is no typed helper for yet:
+//
+//	simple string -> string
+//	bulk string   -> []byte
+//	integer       -> int64
+//	array         -> []interface{}, elements mapped recursively
+//	nil           -> nil
+//
+// Error replies are returned as the error.
+func DoTree(tag, cmd string, args ...string) (interface{}, error) {
+	var reply interface{}
+	if err := DoCmd(&reply, tag, cmd, args...); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func replyFloat(v interface{}) (float64, error) {
+	s, err := replyString(v)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// DoRawMessage runs a command and returns its reply as JSON for forwarding
+// without decoding it into Go values first. String replies holding valid
+// JSON are returned verbatim, other strings as JSON strings. Integers,
+// arrays and nil are encoded as JSON numbers, arrays and null.
+func DoRawMessage(tag, cmd string, args ...string) (json.RawMessage, error) {
+	reply, err := DoTree(tag, cmd, args...)
+	if err != nil {
+		return nil, err
+	}
+	if b, ok := reply.([]byte); ok && json.Valid(b) {
+		return json.RawMessage(b), nil
+	}
+	return json.Marshal(jsonReply(reply))
+}
+
+// jsonReply converts a reply tree so that bulk strings are encoded as JSON
+// strings instead of base64.
+func jsonReply(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case []byte:
+		return string(vv)
+	case []interface{}:
+		ret := make([]interface{}, len(vv))
+		for i, e := range vv {
+			ret[i] = jsonReply(e)
+		}
+		return ret
+	}
+	return v
+}
@@ -0,0 +1,305 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+var errUnexpectedScanReply = errors.New("Unexpected SCAN reply")
+
+// Iterator walks the keyspace of a tag with SCAN. On cluster every primary is
+// scanned one after another.
+type Iterator struct {
+	tag     string
+	match   string
+	keyType string
+	count   int
+	nodes   []nodeClient
+
+	node   int
+	cursor string
+	buf    []string
+	key    string
+	err    error
+
+	// position the keys in buf were fetched from
+	pageNode   int
+	pageCursor string
+
+	limit    int
+	returned int
+}
+
+func ScanKeys(tag, match string, count int) (*Iterator, error) {
+	nodes, err := primaryClients(tag)
+	if err != nil {
+		return nil, err
+	}
+	// a stable node order lets a saved cursor skip the nodes already done
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].addr < nodes[j].addr })
+	return &Iterator{
+		tag:    tag,
+		match:  match,
+		count:  count,
+		nodes:  nodes,
+		cursor: "0",
+	}, nil
+}
+
+var keyTypes = map[string]bool{
+	"string": true,
+	"list":   true,
+	"set":    true,
+	"zset":   true,
+	"hash":   true,
+	"stream": true,
+}
+
+// ScanType only returns keys of keyType (string, list, set, zset, hash or
+// stream), it needs Redis 6.
+func ScanType(tag, match, keyType string, count int) (*Iterator, error) {
+	keyType = strings.ToLower(keyType)
+	if !keyTypes[keyType] {
+		return nil, fmt.Errorf("Unknown key type [%s]", keyType)
+	}
+
+	it, err := ScanKeys(tag, match, count)
+	if err != nil {
+		return nil, err
+	}
+	it.keyType = keyType
+	return it, nil
+}
+
+// Next advances to the next key, it returns false when the scan is complete
+// or an error occurred, see Err.
+func (it *Iterator) Next() bool {
+	if it.limit > 0 && it.returned >= it.limit {
+		return false
+	}
+	for len(it.buf) == 0 {
+		if it.err != nil || it.node >= len(it.nodes) {
+			return false
+		}
+		if it.err = it.scan(); it.err != nil {
+			return false
+		}
+	}
+	it.key, it.buf = it.buf[0], it.buf[1:]
+	it.returned++
+	return true
+}
+
+// Limit makes Next stop after n keys, no further SCAN is sent once they are
+// collected. n <= 0 removes the limit.
+func (it *Iterator) Limit(n int) *Iterator {
+	it.limit = n
+	return it
+}
+
+func (it *Iterator) Key() string {
+	return it.key
+}
+
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Cursor returns a checkpoint to continue the scan later with ResumeScan. On
+// cluster it holds the address of the node being scanned and its cursor,
+// "addr|cursor". While keys of the current page are still unread the
+// checkpoint points at that page, so after resuming some keys may be
+// returned again. It returns "" once the scan is complete.
+func (it *Iterator) Cursor() string {
+	node, cursor := it.node, it.cursor
+	if len(it.buf) > 0 {
+		node, cursor = it.pageNode, it.pageCursor
+	}
+	if node >= len(it.nodes) {
+		return ""
+	}
+	if len(it.nodes[node].addr) == 0 {
+		return cursor
+	}
+	return it.nodes[node].addr + "|" + cursor
+}
+
+// ResumeScan continues a scan from a checkpoint returned by Cursor. An empty
+// cursor means the scan was complete, the iterator then yields nothing.
+func ResumeScan(tag, match string, cursor string, count int) (*Iterator, error) {
+	it, err := ScanKeys(tag, match, count)
+	if err != nil {
+		return nil, err
+	}
+	if len(cursor) == 0 {
+		it.node = len(it.nodes)
+		return it, nil
+	}
+
+	i := strings.LastIndex(cursor, "|")
+	if i < 0 {
+		if len(it.nodes) != 1 || len(it.nodes[0].addr) > 0 {
+			return nil, fmt.Errorf("Invalid scan cursor [%s] for tag [%s], want addr|cursor", cursor, tag)
+		}
+		it.cursor = cursor
+		return it, nil
+	}
+
+	addr, nodeCursor := cursor[:i], cursor[i+1:]
+	for n := range it.nodes {
+		if it.nodes[n].addr == addr {
+			it.node, it.cursor = n, nodeCursor
+			return it, nil
+		}
+	}
+	return nil, fmt.Errorf("Scan cursor node [%s] is not a primary of tag [%s]", addr, tag)
+}
+
+func (it *Iterator) scanArgs() []string {
+	args := []string{it.cursor}
+	if len(it.match) > 0 {
+		args = append(args, "MATCH", it.match)
+	}
+	if it.count > 0 {
+		args = append(args, "COUNT", strconv.Itoa(it.count))
+	}
+	if len(it.keyType) > 0 {
+		args = append(args, "TYPE", it.keyType)
+	}
+	return args
+}
+
+// scan fetches one page from the current node and moves to the next node
+// once its cursor wraps to 0.
+func (it *Iterator) scan() error {
+	t := time.Now()
+	n := it.nodes[it.node]
+	it.pageNode, it.pageCursor = it.node, it.cursor
+
+	var reply []interface{}
+	err := wrapErr(it.tag, "SCAN", doChecked(it.tag, n.client, radix.Cmd(&reply, "SCAN", it.scanArgs()...), "SCAN"))
+	logInfo("redis.Scan cost:%v tag:%s node:%s cursor:%s err:%v", time.Since(t), it.tag, n.addr, it.cursor, err)
+	if err != nil {
+		return err
+	}
+	if len(reply) != 2 {
+		return errUnexpectedScanReply
+	}
+
+	cursor, err := replyString(reply[0])
+	if err != nil {
+		return err
+	}
+	keys, err := replyStrings(reply[1])
+	if err != nil {
+		return err
+	}
+
+	it.buf = keys
+	it.cursor = cursor
+	if cursor == "0" {
+		it.node++
+	}
+	return nil
+}
+
+// Keys collects every key matching match into a slice using SCAN instead of
+// the blocking KEYS command. All matching keys are held in memory, so it is
+// meant for small keyspaces and admin tools; pass max to stop after that many
+// keys. On cluster keys of all primaries are returned.
+func Keys(tag, match string, max ...int) ([]string, error) {
+	limit := 0
+	if len(max) > 0 {
+		limit = max[0]
+	}
+
+	it, err := ScanKeys(tag, match, 0)
+	if err != nil {
+		return nil, err
+	}
+	it.Limit(limit)
+
+	keys := []string{}
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// ScanEach calls fn for every key matching match as the scan goes, keeping
+// memory bounded. It stops at the first error returned by fn.
+func ScanEach(tag, match string, count int, fn func(key string) error) error {
+	return ScanEachContext(context.Background(), tag, match, count, fn)
+}
+
+// ScanEachContext is ScanEach that also stops once ctx is done.
+func ScanEachContext(ctx context.Context, tag, match string, count int, fn func(key string) error) error {
+	it, err := ScanKeys(tag, match, count)
+	if err != nil {
+		return err
+	}
+	for it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(it.Key()); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+// FindKeysWithoutTTL returns keys matching match that never expire, at most
+// limit of them when limit > 0. Every SCAN page is checked with one pipeline
+// of TTL on the node it came from.
+func FindKeysWithoutTTL(tag, match string, limit int) ([]string, error) {
+	t := time.Now()
+	it, err := ScanKeys(tag, match, 100)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	scanned := 0
+	for it.node < len(it.nodes) && (limit <= 0 || len(keys) < limit) {
+		if err := it.scan(); err != nil {
+			return nil, err
+		}
+		page, n := it.buf, it.nodes[it.pageNode]
+		it.buf = nil
+		if len(page) == 0 {
+			continue
+		}
+		scanned += len(page)
+
+		ttls := make([]int64, len(page))
+		cmds := make([]radix.CmdAction, len(page))
+		for i, key := range page {
+			cmds[i] = radix.Cmd(&ttls[i], "TTL", key)
+		}
+		if err := doChecked(tag, n.client, radix.Pipeline(cmds...), "TTL"); err != nil {
+			return nil, wrapErr(tag, "TTL", err)
+		}
+		for i, ttl := range ttls {
+			if ttl == -1 {
+				keys = append(keys, page[i])
+			}
+		}
+	}
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	logInfo("redis.FindKeysWithoutTTL cost:%v tag:%s match:%s scanned:%d found:%d", time.Since(t), tag, match, scanned, len(keys))
+	return keys, nil
+}
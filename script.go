@@ -0,0 +1,124 @@
+package redis
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+// set by SetScriptKillOnBusy, accessed atomically
+var scriptKillOnBusy int32
+
+// SetScriptKillOnBusy makes EvalSmart answer a BUSY reply, another script
+// running too long, with SCRIPT KILL and one retry. It is off by default as
+// it aborts the script of someone else; scripts that already wrote can't be
+// killed and the BUSY error is returned. OOM replies are never retried,
+// ClassifyError reports them as KindOOM.
+func SetScriptKillOnBusy(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&scriptKillOnBusy, v)
+}
+
+// killScript sends SCRIPT KILL to the node of keys and reports whether the
+// busy script is gone.
+func killScript(client radix.Client, tag string, keys []string) bool {
+	var key string
+	if len(keys) > 0 {
+		key = keys[0]
+	}
+	var ret string
+	err := doChecked(tag, client, radix.WithConn(key, func(conn radix.Conn) error {
+		return conn.Do(radix.Cmd(&ret, "SCRIPT", "KILL"))
+	}), "SCRIPT")
+	logWarn("redis.EvalSmart tag:%s script busy, SCRIPT KILL err:%v", tag, err)
+	return err == nil || strings.HasPrefix(err.Error(), "NOTBUSY")
+}
+
+// LoadScriptFile reads a Lua script for EvalSmart. SHA is left empty, the
+// script is sent with SCRIPT LOAD on its first EvalSmart.
+func LoadScriptFile(path string) (*LuaScript, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &LuaScript{Script: string(b)}, nil
+}
+
+// LoadScriptDir loads every .lua file of dir, keyed by file name without the
+// extension. Sub directories are not walked.
+func LoadScriptDir(dir string) (map[string]*LuaScript, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.lua"))
+	if err != nil {
+		return nil, err
+	}
+
+	scripts := make(map[string]*LuaScript, len(paths))
+	for _, path := range paths {
+		script, err := LoadScriptFile(path)
+		if err != nil {
+			return nil, err
+		}
+		scripts[strings.TrimSuffix(filepath.Base(path), ".lua")] = script
+	}
+	return scripts, nil
+}
+
+// ScriptLoad loads script into the script cache of every primary and returns
+// its SHA, so EVALSHA works whichever node a key routes to. Failed nodes are
+// reported as NodeErrors.
+func ScriptLoad(tag, script string) (string, error) {
+	var sha string
+	err := fanOutPrimaries(tag, "SCRIPT LOAD", func(n nodeClient) error {
+		return doChecked(tag, n.client, radix.Cmd(&sha, "SCRIPT", "LOAD", script), "SCRIPT")
+	})
+	return sha, err
+}
+
+// ScriptFlush empties the script cache of every primary.
+func ScriptFlush(tag string, async bool) error {
+	args := []string{"FLUSH"}
+	if async {
+		args = append(args, "ASYNC")
+	}
+	return fanOutPrimaries(tag, "SCRIPT FLUSH", func(n nodeClient) error {
+		var ret string
+		return doChecked(tag, n.client, radix.Cmd(&ret, "SCRIPT", args...), "SCRIPT")
+	})
+}
+
+// fanOutPrimaries runs fn on every primary of tag, all of them even when
+// some fail.
+func fanOutPrimaries(tag, cmd string, fn func(n nodeClient) error) error {
+	if err := checkCommand(tag, cmd); err != nil {
+		return err
+	}
+	nodes, err := primaryClients(tag)
+	if err != nil {
+		return err
+	}
+
+	errs := NodeErrors{}
+	for _, n := range nodes {
+		t := time.Now()
+		err := wrapErr(tag, cmd, fn(n))
+		logInfo("redis.FanOut cost:%v tag:%s cmd:%s node:%s err:%v", time.Since(t), tag, cmd, n.addr, err)
+		if err != nil {
+			addr := n.addr
+			if len(addr) == 0 {
+				addr = tag
+			}
+			errs[addr] = err
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+// set by SetAllowFlush, accessed atomically
+var allowFlush int32
+
+// SetAllowFlush enables FlushDB, which returns ErrFlushDisabled by default so
+// that a production keyspace can't be flushed by accident.
+func SetAllowFlush(allow bool) {
+	var v int32
+	if allow {
+		v = 1
+	}
+	atomic.StoreInt32(&allowFlush, v)
+}
+
+// DBSize returns the number of keys, summed over all primaries on cluster.
+func DBSize(tag string) (int64, error) {
+	nodes, err := primaryClients(tag)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, n := range nodes {
+		var size int64
+		if err := doChecked(tag, n.client, radix.Cmd(&size, "DBSIZE"), "DBSIZE"); err != nil {
+			return 0, wrapErr(tag, "DBSIZE", err)
+		}
+		total += size
+	}
+	return total, nil
+}
+
+// SizeGuard is a per-call safety rail for FlushDBGuarded and DeleteMatching.
+// With ConfirmIfLargerThan > 0 the keys the operation would remove are
+// counted first and ErrTooManyKeys is returned if there are more, unless
+// Force is set.
+type SizeGuard struct {
+	ConfirmIfLargerThan int64
+	Force               bool
+}
+
+func (g SizeGuard) enabled() bool {
+	return !g.Force && g.ConfirmIfLargerThan > 0
+}
+
+func (g SizeGuard) check(tag string, n int64) error {
+	if g.enabled() && n > g.ConfirmIfLargerThan {
+		return fmt.Errorf("%w: %d keys on tag [%s], confirm limit %d", ErrTooManyKeys, n, tag, g.ConfirmIfLargerThan)
+	}
+	return nil
+}
+
+// FlushDB removes all keys of the tag, on cluster of every primary.
+func FlushDB(tag string, async bool) error {
+	return FlushDBGuarded(tag, async, SizeGuard{})
+}
+
+// FlushDBGuarded is FlushDB that first checks DBSize against guard.
+func FlushDBGuarded(tag string, async bool, guard SizeGuard) error {
+	if atomic.LoadInt32(&allowFlush) == 0 {
+		return ErrFlushDisabled
+	}
+	if guard.enabled() {
+		size, err := DBSize(tag)
+		if err != nil {
+			return err
+		}
+		if err := guard.check(tag, size); err != nil {
+			return err
+		}
+	}
+
+	nodes, err := primaryClients(tag)
+	if err != nil {
+		return err
+	}
+
+	var args []string
+	if async {
+		args = append(args, "ASYNC")
+	}
+	for _, n := range nodes {
+		t := time.Now()
+		err := wrapErr(tag, "FLUSHDB", doChecked(tag, n.client, radix.Cmd(nil, "FLUSHDB", args...), "FLUSHDB"))
+		logInfo("redis.FlushDB cost:%v tag:%s node:%s async:%v err:%v", time.Since(t), tag, n.addr, async, err)
+		if err != nil {
+			return err
+		}
+	}
+	invalidateCached(tag, "FLUSHDB", nil)
+	return nil
+}
+
+// key - tag + " " + upper case command name
+// value - bool
+var commandCache sync.Map
+
+// CommandExists reports whether the server of tag knows cmd, using COMMAND
+// INFO. Results are cached per tag for the life of the process.
+func CommandExists(tag, cmd string) (bool, error) {
+	cmd = strings.ToUpper(cmd)
+	cacheKey := tag + " " + cmd
+	if v, ok := commandCache.Load(cacheKey); ok {
+		return v.(bool), nil
+	}
+
+	var reply []interface{}
+	if err := DoCmd(&reply, tag, "COMMAND", "INFO", cmd); err != nil {
+		return false, err
+	}
+	exists := len(reply) > 0 && reply[0] != nil
+	commandCache.Store(cacheKey, exists)
+	return exists, nil
+}
+
+// key - tag
+// value - redis_version of INFO server
+var serverVersions sync.Map
+
+// detectServerVersion caches the version of the server behind tag, it is run
+// at init so version-gated helpers don't need a round trip.
+func detectServerVersion(tag string) {
+	if _, err := ServerVersion(tag); err != nil {
+		logWarn("redis.ServerVersion tag:%s err:%v", tag, err)
+	}
+}
+
+// ServerVersion returns redis_version of INFO server, e.g. "7.0.11". On
+// cluster the version of a random node is taken.
+func ServerVersion(tag string) (string, error) {
+	if v, ok := serverVersions.Load(tag); ok {
+		return v.(string), nil
+	}
+
+	var info string
+	if err := DoCmd(&info, tag, "INFO", "server"); err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "redis_version:") {
+			v := strings.TrimPrefix(line, "redis_version:")
+			serverVersions.Store(tag, v)
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("No redis_version in INFO server of tag [%s]", tag)
+}
+
+// requireVersion returns ErrUnsupportedVersion when the server of tag is
+// older than min. An unknown version is let through, the server will tell.
+func requireVersion(tag, min string) error {
+	v, err := ServerVersion(tag)
+	if err != nil {
+		return nil
+	}
+	if compareVersion(v, min) < 0 {
+		return fmt.Errorf("%w: tag [%s] runs %s, need %s", ErrUnsupportedVersion, tag, v, min)
+	}
+	return nil
+}
+
+func compareVersion(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// set by SetAllowDebug, accessed atomically
+var allowDebug int32
+
+// SetAllowDebug enables DebugSleep and DebugObject, meant for tests only.
+// Note that many managed Redis providers reject DEBUG altogether.
+func SetAllowDebug(allow bool) {
+	var v int32
+	if allow {
+		v = 1
+	}
+	atomic.StoreInt32(&allowDebug, v)
+}
+
+// DebugSleep blocks the server for d, handy to test timeouts.
+func DebugSleep(tag string, d time.Duration) error {
+	if atomic.LoadInt32(&allowDebug) == 0 {
+		return ErrDebugDisabled
+	}
+	var ret string
+	return DoCmd(&ret, tag, "DEBUG", "SLEEP", strconv.FormatFloat(d.Seconds(), 'f', -1, 64))
+}
+
+func DebugObject(tag, key string) (string, error) {
+	if atomic.LoadInt32(&allowDebug) == 0 {
+		return "", ErrDebugDisabled
+	}
+	var ret string
+	err := DoCmd(&ret, tag, "DEBUG", "OBJECT", key)
+	return ret, err
+}
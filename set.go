@@ -0,0 +1,152 @@
+package redis
+
+import (
+	"strconv"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func SAdd(tag, key string, members ...interface{}) (int64, error) {
+	var n int64
+	err := Do(&n, tag, "SADD", key, members...)
+	return n, err
+}
+
+// SMembers returns an empty (non-nil) slice for a missing key.
+func SMembers(tag, key string) ([]string, error) {
+	var ret []string
+	if err := Do(&ret, tag, "SMEMBERS", key); err != nil {
+		return nil, err
+	}
+	if ret == nil {
+		ret = []string{}
+	}
+	return ret, nil
+}
+
+func SIsMember(tag, key, member string) (bool, error) {
+	var n int64
+	err := Do(&n, tag, "SISMEMBER", key, member)
+	return n == 1, err
+}
+
+func SCard(tag, key string) (int64, error) {
+	var n int64
+	err := Do(&n, tag, "SCARD", key)
+	return n, err
+}
+
+// SMIsMember returns a bool per member, in order. Servers older than 6.2 have
+// no SMISMEMBER, then SISMEMBER is pipelined for every member instead.
+func SMIsMember(tag, key string, members ...string) ([]bool, error) {
+	if len(members) == 0 {
+		return []bool{}, nil
+	}
+
+	args := make([]interface{}, len(members))
+	for i, m := range members {
+		args[i] = m
+	}
+	var replies []int64
+	err := Do(&replies, tag, "SMISMEMBER", key, args...)
+	if isUnknownCommandErr(err) {
+		replies, err = sIsMemberPipeline(tag, key, members)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]bool, len(replies))
+	for i, n := range replies {
+		ret[i] = n == 1
+	}
+	return ret, nil
+}
+
+func sIsMemberPipeline(tag, key string, members []string) ([]int64, error) {
+	client, err := getClientByTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	replies := make([]int64, len(members))
+	cmds := make([]radix.CmdAction, len(members))
+	for i, m := range members {
+		cmds[i] = radix.Cmd(&replies[i], "SISMEMBER", key, m)
+	}
+	if err = doChecked(tag, client, radix.Pipeline(cmds...), "SISMEMBER"); err != nil {
+		return nil, wrapErr(tag, "SISMEMBER", err)
+	}
+	return replies, nil
+}
+
+// SMove atomically moves member from src to dst, false means member was not
+// in src. On cluster src and dst must share a slot.
+func SMove(tag, src, dst, member string) (bool, error) {
+	if err := checkSameSlot(tag, src, dst); err != nil {
+		return false, err
+	}
+	var n int64
+	err := DoCmd(&n, tag, "SMOVE", src, dst, member)
+	return n == 1, err
+}
+
+// SRandMember returns count random members, a negative count allows the same
+// member more than once. A missing key gives an empty slice.
+func SRandMember(tag, key string, count int) ([]string, error) {
+	var ret []string
+	if err := Do(&ret, tag, "SRANDMEMBER", key, count); err != nil {
+		return nil, err
+	}
+	if ret == nil {
+		ret = []string{}
+	}
+	return ret, nil
+}
+
+// SInterCard returns the cardinality of the intersection of keys (Redis 7),
+// stopping at limit when limit > 0.
+func SInterCard(tag string, keys []string, limit int) (int64, error) {
+	if err := requireVersion(tag, "7.0.0"); err != nil {
+		return 0, err
+	}
+	if err := checkSameSlot(tag, keys...); err != nil {
+		return 0, err
+	}
+
+	args := append([]string{strconv.Itoa(len(keys))}, keys...)
+	if limit > 0 {
+		args = append(args, "LIMIT", strconv.Itoa(limit))
+	}
+	var n int64
+	err := DoCmd(&n, tag, "SINTERCARD", args...)
+	return n, err
+}
+
+// SInterStore stores the intersection of keys into dst and returns the
+// number of elements in dst. On cluster dst and all keys must share a slot,
+// ErrCrossSlot is returned before anything is sent otherwise. The same holds
+// for SUnionStore and SDiffStore.
+func SInterStore(tag, dst string, keys ...string) (int64, error) {
+	return setStore(tag, "SINTERSTORE", dst, keys)
+}
+
+func SUnionStore(tag, dst string, keys ...string) (int64, error) {
+	return setStore(tag, "SUNIONSTORE", dst, keys)
+}
+
+// SDiffStore stores the members of the first key not in any of the others.
+func SDiffStore(tag, dst string, keys ...string) (int64, error) {
+	return setStore(tag, "SDIFFSTORE", dst, keys)
+}
+
+func setStore(tag, cmd, dst string, keys []string) (int64, error) {
+	args := append([]string{dst}, keys...)
+	if err := checkSameSlot(tag, args...); err != nil {
+		return 0, err
+	}
+
+	var n int64
+	err := DoCmd(&n, tag, cmd, args...)
+	return n, err
+}
@@ -0,0 +1,65 @@
+package redis
+
+import "strconv"
+
+type SortOptions struct {
+	By  string
+	Get []string
+	// LIMIT Offset Count is sent when Count > 0
+	Offset, Count int
+	Desc          bool
+	Alpha         bool
+	Store         string
+}
+
+func (o SortOptions) args(key string) []string {
+	args := []string{key}
+	if len(o.By) > 0 {
+		args = append(args, "BY", o.By)
+	}
+	if o.Count > 0 {
+		args = append(args, "LIMIT", strconv.Itoa(o.Offset), strconv.Itoa(o.Count))
+	}
+	for _, g := range o.Get {
+		args = append(args, "GET", g)
+	}
+	if o.Desc {
+		args = append(args, "DESC")
+	}
+	if o.Alpha {
+		args = append(args, "ALPHA")
+	}
+	if len(o.Store) > 0 {
+		args = append(args, "STORE", o.Store)
+	}
+	return args
+}
+
+// Sort runs SORT_RO, which may be served by replicas, unless opts.Store is
+// set or the server predates Redis 7; then SORT is used. With Store the
+// result is written to that key and nil is returned. BY and GET patterns
+// read other keys and generally fail on cluster.
+func Sort(tag, key string, opts SortOptions) ([]string, error) {
+	if isCluster(tag) && (len(opts.By) > 0 || len(opts.Get) > 0) {
+		logWarn("redis.Sort tag:%s key:%s BY/GET patterns are not supported on cluster", tag, key)
+	}
+
+	args := opts.args(key)
+	if len(opts.Store) > 0 {
+		var n int64
+		return nil, DoCmd(&n, tag, "SORT", args...)
+	}
+
+	var ret []string
+	err := DoCmd(&ret, tag, "SORT_RO", args...)
+	if isUnknownCommandErr(err) {
+		err = DoCmd(&ret, tag, "SORT", args...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ret == nil {
+		ret = []string{}
+	}
+	return ret, nil
+}
@@ -0,0 +1,91 @@
+package redis
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+type Stats struct {
+	// idle connections in the pools of the tag, summed over all primaries
+	// on cluster
+	AvailConns int
+	// connections currently held longer than conn_leak_timeout
+	LeakedConns int64
+}
+
+func PoolStats(tag string) (Stats, error) {
+	client, err := getClientByTag(tag)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	var s Stats
+	switch cc := client.(type) {
+	case *radix.Pool:
+		s.AvailConns = cc.NumAvailConns()
+	case *radix.Sentinel:
+		addr, _ := cc.Addrs()
+		if c, err := cc.Client(addr); err == nil {
+			if p, ok := c.(*radix.Pool); ok {
+				s.AvailConns = p.NumAvailConns()
+			}
+		}
+	case *radix.Cluster:
+		nodes, err := primaryClients(tag)
+		if err != nil {
+			return Stats{}, err
+		}
+		for _, n := range nodes {
+			if p, ok := n.client.(*radix.Pool); ok {
+				s.AvailConns += p.NumAvailConns()
+			}
+		}
+	}
+	s.LeakedConns = atomic.LoadInt64(&getOptionByTag(tag).leaked)
+	return s, nil
+}
+
+// used by StartPoolMetrics for an interval <= 0
+var defaultMetricsInterval = 10 * time.Second
+
+// StartPoolMetrics calls emit with the PoolStats of every tag each interval,
+// tags are looked up on every tick so removed ones stop being sampled. An
+// interval <= 0 falls back to 10s. Call the returned func to stop.
+func StartPoolMetrics(interval time.Duration, emit func(tag string, s Stats)) (stop func()) {
+	if interval <= 0 {
+		logWarn("redis.StartPoolMetrics invalid interval %v, using %v", interval, defaultMetricsInterval)
+		interval = defaultMetricsInterval
+	}
+	stopCh := make(chan struct{})
+	var once sync.Once
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-stopCh:
+				return
+			}
+			clientMap.Range(func(k, v interface{}) bool {
+				tag := k.(string)
+				s, err := PoolStats(tag)
+				if err != nil {
+					logWarn("redis.PoolStats tag:%s err:%v", tag, err)
+					return true
+				}
+				safeCall("StartPoolMetrics", func() { emit(tag, s) })
+				return true
+			})
+		}
+	}()
+
+	return func() {
+		once.Do(func() {
+			close(stopCh)
+		})
+	}
+}
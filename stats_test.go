@@ -0,0 +1,39 @@
+package redis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStartPoolMetrics(t *testing.T) {
+	_, tag := newTestTag(t)
+
+	emitted := make(chan Stats, 1)
+	stop := StartPoolMetrics(time.Millisecond, func(got string, s Stats) {
+		if got != tag {
+			return
+		}
+		select {
+		case emitted <- s:
+		default:
+		}
+	})
+	defer stop()
+
+	select {
+	case s := <-emitted:
+		if s.AvailConns == 0 {
+			t.Fatalf("got %+v, want idle conns", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no stats emitted")
+	}
+}
+
+func TestStartPoolMetricsBadInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		stop := StartPoolMetrics(interval, func(string, Stats) {})
+		stop()
+		stop()
+	}
+}
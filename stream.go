@@ -0,0 +1,171 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+type StreamEntry struct {
+	ID     string
+	Fields map[string]string
+}
+
+type XPendingSummary struct {
+	Count int64
+	// smallest and greatest pending ID, empty when nothing is pending
+	Lowest  string
+	Highest string
+	// pending entries per consumer
+	Consumers map[string]int64
+}
+
+// parseStreamEntries parses [[id, [field, value, ...]], ...] replies. Entries
+// deleted meanwhile come back as nil and are skipped.
+func parseStreamEntries(v interface{}) ([]StreamEntry, error) {
+	arr, err := replyArray(v)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]StreamEntry, 0, len(arr))
+	for _, e := range arr {
+		pair, err := replyArray(e)
+		if err != nil {
+			return nil, err
+		}
+		if len(pair) == 0 {
+			continue
+		}
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("Unexpected stream entry reply of %d elements", len(pair))
+		}
+
+		var entry StreamEntry
+		if entry.ID, err = replyString(pair[0]); err != nil {
+			return nil, err
+		}
+		fields, err := replyStrings(pair[1])
+		if err != nil {
+			return nil, err
+		}
+		if len(fields)%2 != 0 {
+			return nil, fmt.Errorf("Unexpected stream entry fields length %d", len(fields))
+		}
+		entry.Fields = make(map[string]string, len(fields)/2)
+		for i := 0; i < len(fields); i += 2 {
+			entry.Fields[fields[i]] = fields[i+1]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// XLen returns 0 for a missing stream.
+func XLen(tag, stream string) (int64, error) {
+	var n int64
+	err := Do(&n, tag, "XLEN", stream)
+	return n, err
+}
+
+// XRange returns the entries with IDs between start and end, inclusive, "-"
+// and "+" meaning the first and last entry. count <= 0 returns all of them.
+func XRange(tag, stream, start, end string, count int) ([]StreamEntry, error) {
+	args := []string{stream, start, end}
+	if count > 0 {
+		args = append(args, "COUNT", strconv.Itoa(count))
+	}
+
+	var reply []interface{}
+	if err := DoCmd(&reply, tag, "XRANGE", args...); err != nil {
+		return nil, err
+	}
+	return parseStreamEntries(reply)
+}
+
+// XTrim caps the stream at maxLen entries and returns how many were removed.
+// With approx ("MAXLEN ~") Redis only removes whole macro nodes, which is
+// much cheaper but may keep a few more entries than maxLen.
+func XTrim(tag, stream string, maxLen int64, approx bool) (int64, error) {
+	if maxLen < 0 {
+		return 0, fmt.Errorf("%w: XTRIM MAXLEN %d", ErrBadArgs, maxLen)
+	}
+	args := []string{stream, "MAXLEN"}
+	if approx {
+		args = append(args, "~")
+	}
+	args = append(args, strconv.FormatInt(maxLen, 10))
+
+	var n int64
+	err := DoCmd(&n, tag, "XTRIM", args...)
+	return n, err
+}
+
+// XAutoClaim transfers entries pending longer than minIdle to consumer,
+// starting at ID start ("0-0" for the beginning), and returns the ID to pass
+// as start of the next call, "0-0" once the whole PEL was scanned (Redis
+// 6.2).
+func XAutoClaim(tag, stream, group, consumer string, minIdle time.Duration, start string, count int) (nextStart string, entries []StreamEntry, err error) {
+	args := []string{stream, group, consumer, strconv.FormatInt(minIdle.Milliseconds(), 10), start}
+	if count > 0 {
+		args = append(args, "COUNT", strconv.Itoa(count))
+	}
+
+	var reply []interface{}
+	if err = DoCmd(&reply, tag, "XAUTOCLAIM", args...); err != nil {
+		return "", nil, err
+	}
+	// Redis 7 appends the IDs of deleted entries as a third element
+	if len(reply) < 2 {
+		return "", nil, fmt.Errorf("Unexpected XAUTOCLAIM reply of %d elements", len(reply))
+	}
+	if nextStart, err = replyString(reply[0]); err != nil {
+		return "", nil, err
+	}
+	if entries, err = parseStreamEntries(reply[1]); err != nil {
+		return "", nil, err
+	}
+	return nextStart, entries, nil
+}
+
+func XPending(tag, stream, group string) (XPendingSummary, error) {
+	var reply []interface{}
+	if err := Do(&reply, tag, "XPENDING", stream, group); err != nil {
+		return XPendingSummary{}, err
+	}
+	if len(reply) != 4 {
+		return XPendingSummary{}, fmt.Errorf("Unexpected XPENDING reply of %d elements", len(reply))
+	}
+
+	var s XPendingSummary
+	var err error
+	if s.Count, err = replyInt(reply[0]); err != nil {
+		return XPendingSummary{}, err
+	}
+	if s.Lowest, err = replyString(reply[1]); err != nil {
+		return XPendingSummary{}, err
+	}
+	if s.Highest, err = replyString(reply[2]); err != nil {
+		return XPendingSummary{}, err
+	}
+
+	consumers, err := replyArray(reply[3])
+	if err != nil {
+		return XPendingSummary{}, err
+	}
+	s.Consumers = make(map[string]int64, len(consumers))
+	for _, c := range consumers {
+		pair, err := replyStrings(c)
+		if err != nil {
+			return XPendingSummary{}, err
+		}
+		if len(pair) != 2 {
+			return XPendingSummary{}, fmt.Errorf("Unexpected XPENDING consumer reply of %d elements", len(pair))
+		}
+		n, err := strconv.ParseInt(pair[1], 10, 64)
+		if err != nil {
+			return XPendingSummary{}, err
+		}
+		s.Consumers[pair[0]] = n
+	}
+	return s, nil
+}
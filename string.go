@@ -0,0 +1,284 @@
+package redis
+
+import (
+	"encoding"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func Append(tag, key, value string) (int64, error) {
+	var n int64
+	err := Do(&n, tag, "APPEND", key, value)
+	return n, err
+}
+
+func StrLen(tag, key string) (int64, error) {
+	var n int64
+	err := Do(&n, tag, "STRLEN", key)
+	return n, err
+}
+
+// Incr returns ErrNotInteger when key holds something that isn't an integer,
+// as do IncrBy and HIncrBy.
+func Incr(tag, key string) (int64, error) {
+	var n int64
+	if err := Do(&n, tag, "INCR", key); err != nil {
+		return 0, numberErr(err, key)
+	}
+	return n, nil
+}
+
+func IncrBy(tag, key string, delta int64) (int64, error) {
+	var n int64
+	if err := Do(&n, tag, "INCRBY", key, delta); err != nil {
+		return 0, numberErr(err, key)
+	}
+	return n, nil
+}
+
+// IncrByFloat returns ErrNotFloat when key holds something that isn't a
+// number.
+func IncrByFloat(tag, key string, delta float64) (float64, error) {
+	var f float64
+	if err := Do(&f, tag, "INCRBYFLOAT", key, strconv.FormatFloat(delta, 'f', -1, 64)); err != nil {
+		return 0, numberErr(err, key)
+	}
+	return f, nil
+}
+
+// GetSet sets key to value and returns the previous value, existed is false
+// when key didn't exist before. SET key value GET (Redis 6.2) is used, older
+// servers reject the GET option as a syntax error and get GETSET instead.
+func GetSet(tag, key string, value interface{}) (old string, existed bool, err error) {
+	mn := radix.MaybeNil{Rcv: &old}
+	err = Do(&mn, tag, "SET", key, value, "GET")
+	if isSyntaxErr(err) {
+		mn = radix.MaybeNil{Rcv: &old}
+		err = Do(&mn, tag, "GETSET", key, value)
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return old, !mn.Nil, nil
+}
+
+// SetStrict is SET with an optional ttl (0 keeps the key forever) that
+// refuses values encoding to zero bytes with ErrEmptyValue, as an empty
+// string is usually an unfilled variable. Pass allowEmpty when empty values
+// are intended.
+func SetStrict(tag, key string, value interface{}, ttl time.Duration, allowEmpty bool) error {
+	if !allowEmpty && isEmptyValue(value) {
+		return fmt.Errorf("%w: key [%s]", ErrEmptyValue, key)
+	}
+
+	args := []interface{}{value}
+	if ttl > 0 {
+		args = append(args, "PX", ttl.Milliseconds())
+	}
+	var ret string
+	return Do(&ret, tag, "SET", key, args...)
+}
+
+func isEmptyValue(v interface{}) bool {
+	switch vv := v.(type) {
+	case nil:
+		return true
+	case string:
+		return len(vv) == 0
+	case []byte:
+		return len(vv) == 0
+	case encoding.BinaryMarshaler:
+		b, err := vv.MarshalBinary()
+		return err == nil && len(b) == 0
+	case encoding.TextMarshaler:
+		b, err := vv.MarshalText()
+		return err == nil && len(b) == 0
+	}
+	return false
+}
+
+// SetAndGet sets key and reads it back in one pipelined round trip on the
+// same connection, returning the value as stored to confirm the write.
+func SetAndGet(tag, key string, value interface{}) (stored string, err error) {
+	t := time.Now()
+	defer func() {
+		logInfo("redis.SetAndGet cost:%v tag:%s key:%s err:%v", time.Since(t), tag, key, err)
+	}()
+
+	client, err := getClientByTag(tag)
+	if err != nil {
+		return "", err
+	}
+
+	var ok string
+	err = doChecked(tag, client, radix.Pipeline(
+		radix.FlatCmd(&ok, "SET", key, value),
+		radix.Cmd(&stored, "GET", key),
+	), "SET", "GET")
+	invalidateCached(tag, "SET", []string{key})
+	if err != nil {
+		return "", wrapErr(tag, "SET", err)
+	}
+	return stored, nil
+}
+
+// SetWithDeadline sets key to expire at deadline. SET ... PXAT (Redis 6.2)
+// passes the deadline itself, older servers get PX computed from the local
+// clock.
+func SetWithDeadline(tag, key string, value interface{}, deadline time.Time) error {
+	ttl := time.Until(deadline)
+	if ttl <= 0 {
+		return fmt.Errorf("%w: deadline %v of key [%s] is not in the future", ErrBadArgs, deadline, key)
+	}
+
+	var ret string
+	if requireVersion(tag, "6.2.0") == nil {
+		err := Do(&ret, tag, "SET", key, value, "PXAT", deadline.UnixNano()/int64(time.Millisecond))
+		if !isSyntaxErr(err) {
+			return err
+		}
+	}
+	ttl = time.Until(deadline)
+	if ttl < time.Millisecond {
+		ttl = time.Millisecond
+	}
+	return Do(&ret, tag, "SET", key, value, "PX", ttl.Milliseconds())
+}
+
+// GetRange takes Redis offsets, negative ones count from the end of the
+// string, -1 being the last character.
+func GetRange(tag, key string, start, end int64) (string, error) {
+	var ret string
+	err := Do(&ret, tag, "GETRANGE", key, start, end)
+	return ret, err
+}
+
+// RefreshSession reads key and extends its expiry to ttl in one GETEX (Redis
+// 6.2), so the session can't expire between the read and the refresh. ok is
+// false when the key is gone. PX is used so sub-second durations are kept.
+func RefreshSession(tag, key string, ttl time.Duration) (data string, ok bool, err error) {
+	if ttl < time.Millisecond {
+		return "", false, fmt.Errorf("%w: session ttl %v, want at least 1ms", ErrBadArgs, ttl)
+	}
+	if err := requireVersion(tag, "6.2.0"); err != nil {
+		return "", false, err
+	}
+
+	ok, err = doMaybe(&data, tag, "GETEX", key, "PX", ttl.Milliseconds())
+	return data, ok, err
+}
+
+// SetRange overwrites the string at offset and returns its new length.
+func SetRange(tag, key string, offset int64, value string) (int64, error) {
+	var n int64
+	err := Do(&n, tag, "SETRANGE", key, offset, value)
+	return n, err
+}
+
+// MSet sets all pairs with MSET. On cluster the pairs are grouped by slot and
+// one MSET is sent per slot, so the write is only atomic within a slot and a
+// failure may leave earlier slots written. Pass strict to get ErrCrossSlot
+// instead when the keys span several slots. Values must be single values,
+// a slice, map or struct gives ErrBadArgs.
+func MSet(tag string, pairs map[string]interface{}, strict bool) error {
+	if len(pairs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(pairs))
+	for k, v := range pairs {
+		if !scalarArg(v) {
+			return fmt.Errorf("%w: MSET value of %s is a %T, want a single value", ErrBadArgs, k, v)
+		}
+		keys = append(keys, k)
+	}
+	if strict {
+		if err := checkSameSlot(tag, keys...); err != nil {
+			return err
+		}
+	}
+
+	for _, group := range splitBySlot(tag, keys) {
+		args := make([]interface{}, 0, len(group)*2-1)
+		args = append(args, pairs[group[0]])
+		for _, k := range group[1:] {
+			args = append(args, k, pairs[k])
+		}
+		var ret string
+		if err := Do(&ret, tag, "MSET", group[0], args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const incrCappedScript = `
+local cur = redis.call('GET', KEYS[1])
+local n = tonumber(cur or '0') + tonumber(ARGV[1])
+if n > tonumber(ARGV[2]) then
+	return {tonumber(cur or '0'), 0}
+end
+redis.call('INCRBY', KEYS[1], ARGV[1])
+if not cur and tonumber(ARGV[3]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+return {n, 1}
+`
+
+// IncrCapped atomically adds delta to key unless the result would exceed max,
+// applied tells which. When the increment is refused newValue is the current
+// value. ttl is set only when the key gets created, 0 means no expiry.
+func IncrCapped(tag, key string, delta, max int64, ttl time.Duration) (newValue int64, applied bool, err error) {
+	var reply []int64
+	err = Eval(&reply, tag, incrCappedScript, 1, key,
+		strconv.FormatInt(delta, 10), strconv.FormatInt(max, 10), strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return 0, false, err
+	}
+	if len(reply) != 2 {
+		return 0, false, fmt.Errorf("Unexpected IncrCapped reply of %d elements", len(reply))
+	}
+	return reply[0], reply[1] == 1, nil
+}
+
+// holds a func(string, bool), nil when no callback is set
+var onConsume atomic.Value
+
+// SetOnConsume registers a callback run after every ConsumeToken, e.g. for an
+// audit log. found tells whether the token existed. nil removes the callback.
+func SetOnConsume(f func(key string, found bool)) {
+	onConsume.Store(f)
+}
+
+const getDelScript = `
+local v = redis.call('GET', KEYS[1])
+if v then
+	redis.call('DEL', KEYS[1])
+end
+return v
+`
+
+// ConsumeToken reads and deletes key in one step, so a one-shot token can be
+// used only once. ok is false when the token was already consumed or never
+// existed. GETDEL (Redis 6.2) is used, older servers run the same as a
+// script.
+func ConsumeToken(tag, key string) (value string, ok bool, err error) {
+	mn := radix.MaybeNil{Rcv: &value}
+	err = Do(&mn, tag, "GETDEL", key)
+	if isUnknownCommandErr(err) {
+		mn = radix.MaybeNil{Rcv: &value}
+		err = Eval(&mn, tag, getDelScript, 1, key)
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	ok = !mn.Nil
+	if f, _ := onConsume.Load().(func(string, bool)); f != nil {
+		safeCall("OnConsume", func() { f(key, ok) })
+	}
+	return value, ok, nil
+}
@@ -0,0 +1,11 @@
+package redis
+
+import "testing"
+
+func TestSplitBySlot(t *testing.T) {
+	_, tag := newTestCluster(t)
+
+	if groups := splitBySlot(tag, []string{"{a}k1", "{b}k", "{a}k2"}); len(groups) != 2 {
+		t.Fatalf("got groups %v, want one per slot", groups)
+	}
+}
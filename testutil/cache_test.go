@@ -0,0 +1,83 @@
+package testutil_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LiLeoH/redis"
+)
+
+func enableTestCache(t *testing.T) {
+	redis.EnableClientCache(16, time.Minute)
+	t.Cleanup(func() { redis.EnableClientCache(0, 0) })
+}
+
+func TestGetBytesCopies(t *testing.T) {
+	s, tag := newTestTag(t)
+	enableTestCache(t)
+	s.Set("cache:k", "abc")
+
+	b, _, err := redis.GetBytes(tag, "cache:k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[0] = 'x'
+	b, _, _ = redis.GetBytes(tag, "cache:k")
+	b[1] = 'y'
+	if b, _, _ = redis.GetBytes(tag, "cache:k"); string(b) != "abc" {
+		t.Fatalf("cached value changed to %q by a caller", b)
+	}
+}
+
+func TestCacheInvalidatesAllKeys(t *testing.T) {
+	s, tag := newTestTag(t)
+	enableTestCache(t)
+
+	fill := func(keys ...string) {
+		t.Helper()
+		for _, k := range keys {
+			s.Set(k, "old")
+			if v, _, err := redis.GetString(tag, k); err != nil || v != "old" {
+				t.Fatalf("fill %s: %q %v", k, v, err)
+			}
+		}
+	}
+	check := func(k, want string, found bool) {
+		t.Helper()
+		v, ok, err := redis.GetString(tag, k)
+		if err != nil || v != want || ok != found {
+			t.Fatalf("GET %s got %q %v %v, want %q %v", k, v, ok, err, want, found)
+		}
+	}
+
+	fill("k1", "k2")
+	var n int64
+	if err := redis.DoCmd(&n, tag, "DEL", "k1", "k2"); err != nil {
+		t.Fatal(err)
+	}
+	check("k1", "", false)
+	check("k2", "", false)
+
+	fill("src", "dst")
+	s.Set("src", "new")
+	var ok string
+	if err := redis.DoCmd(&ok, tag, "RENAME", "src", "dst"); err != nil {
+		t.Fatal(err)
+	}
+	check("dst", "new", true)
+
+	fill("m1", "m2")
+	if err := redis.MSet(tag, map[string]interface{}{"m1": "new", "m2": "new"}, false); err != nil {
+		t.Fatal(err)
+	}
+	check("m1", "new", true)
+	check("m2", "new", true)
+
+	fill("f1")
+	redis.SetAllowFlush(true)
+	defer redis.SetAllowFlush(false)
+	if err := redis.FlushDB(tag, false); err != nil {
+		t.Fatal(err)
+	}
+	check("f1", "", false)
+}
@@ -0,0 +1,16 @@
+module github.com/LiLeoH/redis/testutil
+
+go 1.16
+
+require (
+	github.com/LiLeoH/redis v0.0.0-20261016164745-7699b327ea5a
+	github.com/alicebob/miniredis/v2 v2.30.0
+	github.com/mediocregopher/radix/v3 v3.7.0
+	go.uber.org/goleak v1.3.0
+)
+
+// only applies when building inside this repository, so changes to redis can
+// be tested here before they are tagged. Modules requiring testutil ignore it
+// and build against the redis version required above, or a higher one they
+// require themselves.
+replace github.com/LiLeoH/redis => ../
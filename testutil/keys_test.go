@@ -0,0 +1,16 @@
+package testutil_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/LiLeoH/redis"
+)
+
+func TestInspectMissing(t *testing.T) {
+	_, tag := newTestTag(t)
+
+	if _, err := redis.Inspect(tag, "inspect:none"); !errors.Is(err, redis.ErrKeyNotFound) {
+		t.Fatalf("got %v, want ErrKeyNotFound", err)
+	}
+}
@@ -0,0 +1,83 @@
+package testutil_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LiLeoH/redis"
+)
+
+func TestPolicyCoversDirectCalls(t *testing.T) {
+	s, tag := newTestTag(t)
+	s.Set("policy:k", "v")
+	redis.SetAllowFlush(true)
+	defer redis.SetAllowFlush(false)
+
+	denied := func(name string, err error) {
+		t.Helper()
+		if !errors.Is(err, redis.ErrCommandDenied) {
+			t.Errorf("%s got %v, want ErrCommandDenied", name, err)
+		}
+	}
+
+	redis.SetCommandPolicy(tag, redis.CommandPolicy{Deny: []string{"FLUSHDB", "DBSIZE", "RANDOMKEY", "SCAN", "GET", "HGETALL"}})
+	defer redis.SetCommandPolicy(tag, redis.CommandPolicy{})
+
+	denied("FlushDB", redis.FlushDB(tag, false))
+	if !s.Exists("policy:k") {
+		t.Fatal("denied FLUSHDB flushed the db")
+	}
+	_, err := redis.DBSize(tag)
+	denied("DBSize", err)
+	_, _, err = redis.RandomKey(tag)
+	denied("RandomKey", err)
+	it, err := redis.ScanKeys(tag, "*", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for it.Next() {
+	}
+	denied("ScanKeys", it.Err())
+	_, err = redis.SetAndGet(tag, "policy:k", "w")
+	denied("SetAndGet", err)
+	var out []struct{}
+	denied("HGetAllStructMany", redis.HGetAllStructMany(tag, []string{"policy:h"}, &out))
+
+	var ok string
+
+	redis.SetDefaultTTL(tag, time.Minute)
+	redis.SetCommandPolicy(tag, redis.CommandPolicy{Deny: []string{"EVAL", "PEXPIRE"}})
+	denied("Do with a default TTL", redis.Do(&ok, tag, "SET", "policy:ttl", "v"))
+	redis.SetCommandPolicy(tag, redis.CommandPolicy{Deny: []string{"EVAL"}})
+	if err := redis.Do(&ok, tag, "SET", "policy:ttl", "v"); err != nil {
+		t.Fatalf("Do with a default TTL and EVAL denied: %v", err)
+	}
+	if ttl := s.TTL("policy:ttl"); ttl != time.Minute {
+		t.Fatalf("got TTL %v with EVAL denied, want PEXPIRE NX to set 1m", ttl)
+	}
+	redis.SetDefaultTTL(tag, 0)
+	s.Del("policy:ttl")
+
+	redis.SetCommandPolicy(tag, redis.CommandPolicy{Deny: []string{"MULTI"}})
+	denied("Tx", redis.NewTx(tag).Add(&ok, "SET", "policy:k", "w").Exec())
+
+	redis.SetCommandPolicy(tag, redis.CommandPolicy{Deny: []string{"SUBSCRIBE"}})
+	if sub, err := redis.Subscribe(tag, "policy:ch"); err == nil {
+		sub.Close()
+		t.Error("Subscribe got no error, want ErrCommandDenied")
+	} else {
+		denied("Subscribe", err)
+	}
+
+	for _, cmd := range []string{"SCRIPT", "EVAL"} {
+		redis.SetCommandPolicy(tag, redis.CommandPolicy{Deny: []string{cmd}})
+		var ret string
+		denied("EvalSmart loading the script with "+cmd+" denied", redis.EvalSmart(&ret, tag, &redis.LuaScript{Script: "return 'x'"}, 0))
+	}
+
+	redis.SetCommandPolicy(tag, redis.CommandPolicy{})
+	if n, err := redis.DBSize(tag); err != nil || n != 1 {
+		t.Fatalf("DBSize after lifting the policy got %d %v", n, err)
+	}
+}
@@ -0,0 +1,87 @@
+package testutil_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/LiLeoH/redis"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mediocregopher/radix/v3"
+	"go.uber.org/goleak"
+)
+
+// keepalive of radix.PubSub, it only notices the connection was closed on its
+// next tick 5s later and then ends
+var ignorePubSubKeepalive = goleak.IgnoreAnyFunction("github.com/mediocregopher/radix/v3.newPubSub.func1")
+
+// waitClosed reads ch until it is closed.
+func waitClosed(t *testing.T, ch <-chan radix.PubSubMessage) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("Messages not closed")
+		}
+	}
+}
+
+func TestSubscriptionsNoLeak(t *testing.T) {
+	for _, shutdown := range []string{"Close", "Destory"} {
+		t.Run(shutdown, func(t *testing.T) {
+			defer goleak.VerifyNone(t, goleak.IgnoreCurrent(), ignorePubSubKeepalive)
+
+			s, err := miniredis.Run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			tag := t.Name()
+			if err := redis.InitRedisStandalone([]redis.StandaloneConfig{{Tag: tag, Addr: s.Addr()}}); err != nil {
+				t.Fatal(err)
+			}
+
+			sub, err := redis.Subscribe(tag, "leak:ch")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			ctxSub, err := redis.SubscribeCtx(ctx, tag, "leak:ch")
+			if err != nil {
+				t.Fatal(err)
+			}
+			stop := redis.StartPoolMetrics(time.Millisecond, func(string, redis.Stats) {})
+
+			// both are subscribed once a message reaches them
+			for _, ch := range []<-chan radix.PubSubMessage{sub.Messages(), ctxSub.Messages()} {
+				received := false
+				for !received {
+					if _, err := redis.Publish(tag, "leak:ch", "m"); err != nil {
+						t.Fatal(err)
+					}
+					select {
+					case <-ch:
+						received = true
+					case <-time.After(20 * time.Millisecond):
+					}
+				}
+			}
+
+			cancel()
+			waitClosed(t, ctxSub.Messages())
+			stop()
+			if shutdown == "Close" {
+				redis.Close(tag)
+			} else {
+				redis.Destory()
+				redis.Close(tag)
+			}
+			waitClosed(t, sub.Messages())
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package testutil_test
+
+import (
+	"testing"
+
+	"github.com/LiLeoH/redis"
+)
+
+func TestNilVersusEmpty(t *testing.T) {
+	s, tag := newTestTag(t)
+
+	t.Run("string", func(t *testing.T) {
+		s.Set("nil:str", "")
+		if v, ok, err := redis.GetString(tag, "nil:missing"); err != nil || ok || v != "" {
+			t.Fatalf("miss got %q %v %v, want not found", v, ok, err)
+		}
+		if v, ok, err := redis.GetString(tag, "nil:str"); err != nil || !ok || v != "" {
+			t.Fatalf("empty got %q %v %v, want found", v, ok, err)
+		}
+	})
+
+	t.Run("hash", func(t *testing.T) {
+		s.HSet("nil:hash", "empty", "")
+		if v, ok, err := redis.HGet(tag, "nil:missing", "empty"); err != nil || ok || v != "" {
+			t.Fatalf("missing key got %q %v %v, want not found", v, ok, err)
+		}
+		if v, ok, err := redis.HGet(tag, "nil:hash", "other"); err != nil || ok || v != "" {
+			t.Fatalf("missing field got %q %v %v, want not found", v, ok, err)
+		}
+		if v, ok, err := redis.HGet(tag, "nil:hash", "empty"); err != nil || !ok || v != "" {
+			t.Fatalf("empty got %q %v %v, want found", v, ok, err)
+		}
+
+		var out struct {
+			Empty string `redis:"empty"`
+		}
+		if ok, err := redis.HGetAllStruct(tag, "nil:missing", &out); err != nil || ok {
+			t.Fatalf("HGetAllStruct miss got %v %v, want not found", ok, err)
+		}
+		if ok, err := redis.HGetAllStruct(tag, "nil:hash", &out); err != nil || !ok {
+			t.Fatalf("HGetAllStruct got %v %v, want found", ok, err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		s.Push("nil:list", "")
+		if v, ok, err := redis.LIndex(tag, "nil:missing", 0); err != nil || ok || v != "" {
+			t.Fatalf("missing key got %q %v %v, want not found", v, ok, err)
+		}
+		if v, ok, err := redis.LIndex(tag, "nil:list", 5); err != nil || ok || v != "" {
+			t.Fatalf("out of range got %q %v %v, want not found", v, ok, err)
+		}
+		if v, ok, err := redis.LIndex(tag, "nil:list", 0); err != nil || !ok || v != "" {
+			t.Fatalf("empty got %q %v %v, want found", v, ok, err)
+		}
+		if l, err := redis.LRange(tag, "nil:missing", 0, -1); err != nil || l == nil || len(l) != 0 {
+			t.Fatalf("LRange miss got %#v %v, want an empty slice", l, err)
+		}
+	})
+
+	t.Run("set", func(t *testing.T) {
+		if m, err := redis.SMembers(tag, "nil:missing"); err != nil || m == nil || len(m) != 0 {
+			t.Fatalf("SMembers miss got %#v %v, want an empty slice", m, err)
+		}
+		if m, err := redis.SRandMember(tag, "nil:missing", 2); err != nil || m == nil || len(m) != 0 {
+			t.Fatalf("SRandMember miss got %#v %v, want an empty slice", m, err)
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package testutil_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/LiLeoH/redis"
+)
+
+func TestMSetStandalone(t *testing.T) {
+	s, tag := newTestTag(t)
+
+	pairs := map[string]interface{}{"{a}k": "1", "{b}k": 2}
+	// a standalone server has no slots to cross
+	if err := redis.MSet(tag, pairs, true); err != nil {
+		t.Fatal(err)
+	}
+	s.CheckGet(t, "{a}k", "1")
+	s.CheckGet(t, "{b}k", "2")
+
+	// a slice would be flattened and shift the pairs after it
+	err := redis.MSet(tag, map[string]interface{}{"{a}k": []string{"x", "y"}}, false)
+	if !errors.Is(err, redis.ErrBadArgs) {
+		t.Fatalf("slice value got %v, want ErrBadArgs", err)
+	}
+	s.CheckGet(t, "{a}k", "1")
+}
+
+func TestMSetCluster(t *testing.T) {
+	s, tag := newTestCluster(t)
+
+	pairs := map[string]interface{}{"{a}k1": "1", "{a}k2": "2", "{b}k": "3"}
+	if err := redis.MSet(tag, pairs, true); !errors.Is(err, redis.ErrCrossSlot) {
+		t.Fatalf("strict got %v, want ErrCrossSlot", err)
+	}
+	if s.Exists("{a}k1") || s.Exists("{b}k") {
+		t.Fatal("strict MSet wrote keys")
+	}
+
+	if err := redis.MSet(tag, pairs, false); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range pairs {
+		s.CheckGet(t, k, v.(string))
+	}
+
+	if err := redis.MSet(tag, map[string]interface{}{"{a}k1": "x", "{a}k2": "y"}, true); err != nil {
+		t.Fatalf("strict within one slot: %v", err)
+	}
+	s.CheckGet(t, "{a}k1", "x")
+}
@@ -0,0 +1,38 @@
+// Package testutil runs code using github.com/LiLeoH/redis against an
+// in-memory miniredis, so it can be unit tested without a Redis server.
+// It is a module of its own, so only modules requiring testutil depend on
+// miniredis.
+package testutil
+
+import (
+	"testing"
+
+	"github.com/LiLeoH/redis"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// Tag is the standalone tag NewTestRedis registers.
+const Tag = "test"
+
+// NewTestRedis starts a miniredis and registers it as the standalone tag Tag.
+// The returned server can be used to inspect keys or FastForward TTLs, the
+// cleanup func closes the client of Tag, leaving other tags alone, and stops
+// the server.
+func NewTestRedis(t *testing.T) (*miniredis.Miniredis, func()) {
+	t.Helper()
+
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	err = redis.InitRedisStandalone([]redis.StandaloneConfig{{Tag: Tag, Addr: s.Addr()}})
+	if err != nil {
+		s.Close()
+		t.Fatalf("init tag [%s]: %v", Tag, err)
+	}
+
+	return s, func() {
+		redis.Close(Tag)
+		s.Close()
+	}
+}
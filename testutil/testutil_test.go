@@ -0,0 +1,49 @@
+package testutil_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/LiLeoH/redis"
+	"github.com/LiLeoH/redis/testutil"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// The tests of redis that need more of a server than the fake in its own
+// tests, e.g. Lua or data types other than strings, run here against
+// miniredis, so the redis module doesn't depend on it.
+
+func TestMain(m *testing.M) {
+	redis.SetLogInfoFunc(func(format string, a ...interface{}) {})
+	os.Exit(m.Run())
+}
+
+// newTestTag is NewTestRedis with the cleanup registered on t.
+func newTestTag(t *testing.T) (*miniredis.Miniredis, string) {
+	t.Helper()
+
+	s, done := testutil.NewTestRedis(t)
+	t.Cleanup(done)
+	return s, testutil.Tag
+}
+
+// newTestCluster is newTestTag for a cluster tag named after the test,
+// miniredis answers CLUSTER SLOTS as a single node owning all slots.
+func newTestCluster(t *testing.T) (*miniredis.Miniredis, string) {
+	t.Helper()
+
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	tag := t.Name()
+	if err := redis.InitRedisCluster([]redis.ClusterConfig{{Tag: tag, Addrs: []string{s.Addr()}}}); err != nil {
+		s.Close()
+		t.Fatalf("init cluster tag [%s]: %v", tag, err)
+	}
+	t.Cleanup(func() {
+		redis.Close(tag)
+		s.Close()
+	})
+	return s, tag
+}
@@ -0,0 +1,51 @@
+package testutil_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/LiLeoH/redis"
+)
+
+func TestTxSetIncr(t *testing.T) {
+	_, tag := newTestTag(t)
+
+	var set string
+	var n int64
+	err := redis.NewTx(tag).
+		Add(&set, "SET", "tx:name", "v").
+		Add(&n, "INCR", "tx:counter").
+		Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set != "OK" {
+		t.Errorf("SET reply %q, want OK", set)
+	}
+	if n != 1 {
+		t.Errorf("INCR reply %d, want 1", n)
+	}
+}
+
+func TestTxPartialError(t *testing.T) {
+	s, tag := newTestTag(t)
+	s.Set("tx:name", "v")
+
+	var set string
+	var n int64
+	err := redis.NewTx(tag).
+		Add(&set, "SET", "tx:other", "v").
+		Add(&n, "INCR", "tx:name").
+		Exec()
+
+	var txErr *redis.TxError
+	if !errors.As(err, &txErr) {
+		t.Fatalf("got %v, want a TxError", err)
+	}
+	if txErr.Errs[0] != nil || txErr.Errs[1] == nil {
+		t.Fatalf("got errs %v, want only INCR failing", txErr.Errs)
+	}
+	if set != "OK" {
+		t.Errorf("SET reply %q, want OK", set)
+	}
+}
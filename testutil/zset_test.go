@@ -0,0 +1,33 @@
+package testutil_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/LiLeoH/redis"
+)
+
+func TestZAddOpt(t *testing.T) {
+	_, tag := newTestTag(t)
+
+	n, err := redis.ZAddOpt(tag, "zadd:z", redis.ZAddOptions{}, map[string]float64{"a": 1, "b": 2})
+	if err != nil || n != 2 {
+		t.Fatalf("got %v %v, want 2 added", n, err)
+	}
+	n, err = redis.ZAddOpt(tag, "zadd:z", redis.ZAddOptions{GT: true, CH: true}, map[string]float64{"a": 0.5, "b": 3})
+	if err != nil || n != 1 {
+		t.Fatalf("GT CH got %v %v, want 1 changed", n, err)
+	}
+
+	if _, err := redis.ZAddOpt(tag, "zadd:z", redis.ZAddOptions{INCR: true}, map[string]float64{"a": 0.25}); !errors.Is(err, redis.ErrBadArgs) {
+		t.Fatalf("INCR got %v, want ErrBadArgs", err)
+	}
+	score, ok, err := redis.ZAddIncr(tag, "zadd:z", redis.ZAddOptions{}, "a", 0.25)
+	if err != nil || !ok || score != 1.25 {
+		t.Fatalf("ZAddIncr got %v %v %v, want 1.25", score, ok, err)
+	}
+
+	if _, err := redis.ZAddOpt(tag, "zadd:z", redis.ZAddOptions{NX: true, GT: true}, map[string]float64{"a": 1}); err == nil {
+		t.Fatal("NX with GT accepted")
+	}
+}
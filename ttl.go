@@ -0,0 +1,123 @@
+package redis
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+// key - tag
+// value - time.Duration
+var defaultTTLs sync.Map
+
+// writes that may create their key without giving it an expiry
+var ttlWriteCmds = map[string]bool{
+	"SET":          true,
+	"SETNX":        true,
+	"APPEND":       true,
+	"SETRANGE":     true,
+	"SETBIT":       true,
+	"INCR":         true,
+	"INCRBY":       true,
+	"INCRBYFLOAT":  true,
+	"DECR":         true,
+	"DECRBY":       true,
+	"HSET":         true,
+	"HSETNX":       true,
+	"HMSET":        true,
+	"HINCRBY":      true,
+	"HINCRBYFLOAT": true,
+	"LPUSH":        true,
+	"RPUSH":        true,
+	"SADD":         true,
+	"ZADD":         true,
+	"ZINCRBY":      true,
+	"PFADD":        true,
+	"GEOADD":       true,
+	"XADD":         true,
+}
+
+// only sets the expiry of a key that has none, so explicit TTLs are kept
+const defaultTTLScript = `if redis.call('PTTL', KEYS[1]) == -1 then return redis.call('PEXPIRE', KEYS[1], ARGV[1]) end return 0`
+
+// SetDefaultTTL makes every write of Do, DoCmd and their Context variants
+// that may create a key (SET, INCR, HSET, LPUSH, SADD, ZADD, XADD, ...) give
+// the key an expiry of ttl if it has none, enforcing that no key lives
+// forever.
+//
+// This changes the semantics of plain writes and is off by default, ttl <= 0
+// turns it off again. The expiry is set in the same pipeline as the write and
+// never replaces an existing one; SET with EX, PX, EXAT, PXAT or KEEPTTL is
+// left alone. Multi-key writes (MSET, SINTERSTORE, ...), Tx, Pipeline and
+// scripts are not covered.
+func SetDefaultTTL(tag string, ttl time.Duration) {
+	if ttl <= 0 {
+		defaultTTLs.Delete(tag)
+		return
+	}
+	if ttl < time.Millisecond {
+		ttl = time.Millisecond
+	}
+	defaultTTLs.Store(tag, ttl)
+	logInfo("redis.SetDefaultTTL tag:%s ttl:%v", tag, ttl)
+}
+
+// withDefaultTTL appends the default expiry of tag to a write of key, args
+// are the arguments after the key. When the policy of tag denies EVAL the
+// expiry is set with PEXPIRE NX (Redis 7) instead, if that is allowed, else
+// the write fails with ErrCommandDenied.
+func withDefaultTTL(tag, cmd, key string, args []string, a radix.CmdAction) (radix.Action, error) {
+	v, ok := defaultTTLs.Load(tag)
+	if !ok || len(key) == 0 {
+		return a, nil
+	}
+	cmd = strings.ToUpper(cmd)
+	if !ttlWriteCmds[cmd] || (cmd == "SET" && hasTTLOption(args)) {
+		return a, nil
+	}
+
+	ms := strconv.FormatInt(int64(v.(time.Duration)/time.Millisecond), 10)
+	err := checkCommand(tag, "EVAL")
+	if err == nil {
+		return radix.Pipeline(a, radix.Cmd(nil, "EVAL", defaultTTLScript, "1", key, ms)), nil
+	}
+	if checkCommand(tag, "PEXPIRE") == nil && requireVersion(tag, "7.0.0") == nil {
+		return radix.Pipeline(a, radix.Cmd(nil, "PEXPIRE", key, ms, "NX")), nil
+	}
+	return nil, err
+}
+
+func withFlatDefaultTTL(tag, cmd, key string, args []interface{}, a radix.CmdAction) (radix.Action, error) {
+	if _, ok := defaultTTLs.Load(tag); !ok {
+		return a, nil
+	}
+	return withDefaultTTL(tag, cmd, key, flatArgStrings(args), a)
+}
+
+func hasTTLOption(args []string) bool {
+	for _, arg := range args {
+		switch strings.ToUpper(arg) {
+		case "EX", "PX", "EXAT", "PXAT", "KEEPTTL":
+			return true
+		}
+	}
+	return false
+}
+
+// flatArgStrings returns the string arguments of a Do call, other types can't
+// be TTL options and are skipped.
+func flatArgStrings(args []interface{}) []string {
+	ret := make([]string, 0, len(args))
+	for _, arg := range args {
+		switch a := arg.(type) {
+		case string:
+			ret = append(ret, a)
+		case []byte:
+			ret = append(ret, string(a))
+		}
+	}
+	return ret
+}
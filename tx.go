@@ -0,0 +1,130 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+var ErrTxAborted = errors.New("Transaction aborted")
+
+// TxError is returned by Tx.Exec when some of the queued commands failed,
+// Errs[i] holds the error of the i-th command and is nil if it succeeded.
+type TxError struct {
+	Errs []error
+}
+
+func (e *TxError) Error() string {
+	var msgs []string
+	for i, err := range e.Errs {
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("#%d: %v", i, err))
+		}
+	}
+	return "Transaction partially failed: " + strings.Join(msgs, "; ")
+}
+
+type txCmd struct {
+	rcv  interface{}
+	cmd  string
+	args []string
+}
+
+// Tx queues commands and runs them in a MULTI/EXEC block on a single
+// connection, decoding each reply into the receiver given to Add. On cluster
+// all keys must be in the same slot.
+type Tx struct {
+	tag  string
+	key  string
+	cmds []txCmd
+}
+
+func NewTx(tag string) *Tx {
+	return &Tx{tag: tag}
+}
+
+func (tx *Tx) Add(rcv interface{}, cmd string, args ...string) *Tx {
+	cmd = normalizeCmd(cmd)
+	if len(tx.key) == 0 {
+		if keys := radix.Cmd(nil, cmd, args...).Keys(); len(keys) > 0 {
+			tx.key = keys[0]
+		}
+	}
+	tx.cmds = append(tx.cmds, txCmd{rcv: rcv, cmd: cmd, args: args})
+	return tx
+}
+
+func (tx *Tx) Exec() error {
+	t := time.Now()
+	var err error
+	defer func() {
+		logInfo("redis.Tx cost:%v tag:%s cmds:%d err:%v", time.Since(t), tx.tag, len(tx.cmds), err)
+	}()
+
+	client, err := getClientByTag(tx.tag)
+	if err != nil {
+		return err
+	}
+	for _, cmd := range []string{"MULTI", "EXEC"} {
+		if err = checkCommand(tx.tag, cmd); err != nil {
+			return err
+		}
+	}
+	for _, c := range tx.cmds {
+		if err = checkCommand(tx.tag, c.cmd); err != nil {
+			return err
+		}
+	}
+	err = wrapErr(tx.tag, "EXEC", doGuarded(tx.tag, radix.WithConn(tx.key, func(conn radix.Conn) error {
+		return runClean(tx.tag, conn, tx.run)
+	}), client.Do))
+	for _, c := range tx.cmds {
+		invalidateCached(tx.tag, c.cmd, c.args)
+	}
+	return err
+}
+
+func (tx *Tx) run(conn radix.Conn) error {
+	if err := conn.Do(radix.Cmd(nil, "MULTI")); err != nil {
+		return err
+	}
+
+	for _, c := range tx.cmds {
+		if err := conn.Do(radix.Cmd(nil, c.cmd, c.args...)); err != nil {
+			var e resp2.Error
+			if !errors.As(err, &e) {
+				return err
+			}
+			// a command failed to queue, EXEC will report EXECABORT
+		}
+	}
+
+	var replies []resp2.RawMessage
+	mn := radix.MaybeNil{Rcv: &replies}
+	if err := conn.Do(radix.Cmd(&mn, "EXEC")); err != nil {
+		return err
+	}
+	if mn.Nil {
+		return ErrTxAborted
+	}
+
+	txErr := &TxError{Errs: make([]error, len(tx.cmds))}
+	failed := false
+	for i, raw := range replies {
+		if i >= len(tx.cmds) {
+			break
+		}
+		if err := raw.UnmarshalInto(&resp2.Any{I: tx.cmds[i].rcv}); err != nil {
+			txErr.Errs[i] = err
+			failed = true
+		}
+	}
+	if failed {
+		return txErr
+	}
+	return nil
+}
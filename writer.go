@@ -0,0 +1,173 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+var defaultWriterBatch = 100
+var defaultWriterInterval = 100 * time.Millisecond
+
+var errWriterClosed = errors.New("BufferedWriter closed")
+
+// BufferedWriter collects fire-and-forget writes and sends them as pipelines
+// from a background goroutine, once maxBatch commands are queued or every
+// flushInterval. On cluster one pipeline is sent per slot. Failures are
+// reported on Errors().
+type BufferedWriter struct {
+	tag      string
+	maxBatch int
+	interval time.Duration
+
+	mu     sync.Mutex
+	buf    []txCmd
+	closed bool
+
+	flushCh chan struct{}
+	errCh   chan error
+	closeCh chan struct{}
+
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func NewBufferedWriter(tag string, maxBatch int, flushInterval time.Duration) (*BufferedWriter, error) {
+	if _, err := getClientByTag(tag); err != nil {
+		return nil, err
+	}
+	if maxBatch <= 0 {
+		maxBatch = defaultWriterBatch
+	}
+	if flushInterval <= 0 {
+		flushInterval = defaultWriterInterval
+	}
+
+	w := &BufferedWriter{
+		tag:      tag,
+		maxBatch: maxBatch,
+		interval: flushInterval,
+		flushCh:  make(chan struct{}, 1),
+		errCh:    make(chan error, 16),
+		closeCh:  make(chan struct{}),
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w, nil
+}
+
+// Write queues a command, it only fails once the writer is closed.
+func (w *BufferedWriter) Write(cmd string, args ...string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return errWriterClosed
+	}
+	w.buf = append(w.buf, txCmd{cmd: cmd, args: args})
+	if len(w.buf) >= w.maxBatch {
+		select {
+		case w.flushCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Errors reports failed pipelines and commands. Reading it is optional,
+// errors are dropped when nobody keeps up with them.
+func (w *BufferedWriter) Errors() <-chan error {
+	return w.errCh
+}
+
+// Close flushes the queued commands and stops the writer.
+func (w *BufferedWriter) Close() error {
+	w.closeOnce.Do(func() {
+		w.mu.Lock()
+		w.closed = true
+		w.mu.Unlock()
+		close(w.closeCh)
+	})
+	w.wg.Wait()
+	return nil
+}
+
+func (w *BufferedWriter) run() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.flushCh:
+		case <-w.closeCh:
+			w.flush()
+			return
+		}
+		w.flush()
+	}
+}
+
+func (w *BufferedWriter) flush() {
+	w.mu.Lock()
+	cmds := w.buf
+	w.buf = nil
+	w.mu.Unlock()
+	if len(cmds) == 0 {
+		return
+	}
+
+	for _, group := range w.groupBySlot(cmds) {
+		p := NewPipeline(w.tag)
+		for _, c := range group {
+			p.Add(nil, c.cmd, c.args...)
+		}
+		if err := p.Exec(); err != nil {
+			w.reportErr(err)
+			continue
+		}
+		for _, r := range p.Results() {
+			if r.Err != nil {
+				w.reportErr(r.Err)
+			}
+		}
+	}
+}
+
+// groupBySlot keeps the order of commands within a slot.
+func (w *BufferedWriter) groupBySlot(cmds []txCmd) [][]txCmd {
+	if !isCluster(w.tag) {
+		return [][]txCmd{cmds}
+	}
+
+	var order []uint16
+	groups := make(map[uint16][]txCmd)
+	for _, c := range cmds {
+		var slot uint16
+		if keys := radix.Cmd(nil, c.cmd, c.args...).Keys(); len(keys) > 0 {
+			slot = radix.ClusterSlot([]byte(keys[0]))
+		}
+		if _, ok := groups[slot]; !ok {
+			order = append(order, slot)
+		}
+		groups[slot] = append(groups[slot], c)
+	}
+
+	ret := make([][]txCmd, 0, len(order))
+	for _, slot := range order {
+		ret = append(ret, groups[slot])
+	}
+	return ret
+}
+
+func (w *BufferedWriter) reportErr(err error) {
+	logWarn("redis.BufferedWriter tag:%s err:%v", w.tag, err)
+	select {
+	case w.errCh <- fmt.Errorf("BufferedWriter of tag [%s]: %w", w.tag, err):
+	default:
+	}
+}
@@ -0,0 +1,371 @@
+package redis
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+func ZCard(tag, key string) (int64, error) {
+	var n int64
+	err := Do(&n, tag, "ZCARD", key)
+	return n, err
+}
+
+// parseZMemberPairs parses [[member, score], ...] replies.
+func parseZMemberPairs(v interface{}) ([]ZMember, error) {
+	arr, err := replyArray(v)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]ZMember, 0, len(arr))
+	for _, e := range arr {
+		pair, err := replyArray(e)
+		if err != nil {
+			return nil, err
+		}
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("Unexpected member reply of %d elements", len(pair))
+		}
+		var m ZMember
+		if m.Member, err = replyString(pair[0]); err != nil {
+			return nil, err
+		}
+		if m.Score, err = replyFloat(pair[1]); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, nil
+}
+
+// ZMPop pops up to count members from the first non-empty sorted set of keys
+// (Redis 7), the lowest scores when min is true. An empty key and nil
+// members mean all sets were empty.
+func ZMPop(tag string, keys []string, min bool, count int) (key string, members []ZMember, err error) {
+	if err = requireVersion(tag, "7.0.0"); err != nil {
+		return "", nil, err
+	}
+	where := "MAX"
+	if min {
+		where = "MIN"
+	}
+	args := append([]string{strconv.Itoa(len(keys))}, keys...)
+	args = append(args, where)
+	if count > 0 {
+		args = append(args, "COUNT", strconv.Itoa(count))
+	}
+
+	var reply []interface{}
+	if err = DoCmd(&reply, tag, "ZMPOP", args...); err != nil || len(reply) == 0 {
+		return "", nil, err
+	}
+	if len(reply) != 2 {
+		return "", nil, fmt.Errorf("Unexpected ZMPOP reply of %d elements", len(reply))
+	}
+	if key, err = replyString(reply[0]); err != nil {
+		return "", nil, err
+	}
+	if members, err = parseZMemberPairs(reply[1]); err != nil {
+		return "", nil, err
+	}
+	return key, members, nil
+}
+
+// ZAddOptions are the ZADD flags: NX only adds new members, XX only updates
+// existing ones, GT/LT only update when the new score is greater/less, CH
+// counts changed members instead of added ones and INCR adds to the score,
+// which only ZAddIncr takes.
+type ZAddOptions struct {
+	NX, XX, GT, LT bool
+	CH, INCR       bool
+}
+
+func (o ZAddOptions) args() ([]interface{}, error) {
+	if o.NX && (o.XX || o.GT || o.LT) {
+		return nil, fmt.Errorf("%w: ZADD NX can't be combined with XX, GT or LT", ErrBadArgs)
+	}
+	if o.GT && o.LT {
+		return nil, fmt.Errorf("%w: ZADD GT can't be combined with LT", ErrBadArgs)
+	}
+
+	var args []interface{}
+	for _, f := range []struct {
+		on   bool
+		flag string
+	}{{o.NX, "NX"}, {o.XX, "XX"}, {o.GT, "GT"}, {o.LT, "LT"}, {o.CH, "CH"}, {o.INCR, "INCR"}} {
+		if f.on {
+			args = append(args, f.flag)
+		}
+	}
+	return args, nil
+}
+
+// ZAddOpt runs ZADD with opts and returns the number of added members, or of
+// changed members with CH. INCR returns a score, not a count, use ZAddIncr
+// for it.
+func ZAddOpt(tag, key string, opts ZAddOptions, members map[string]float64) (int64, error) {
+	if opts.INCR {
+		return 0, fmt.Errorf("%w: ZADD INCR returns a score, use ZAddIncr", ErrBadArgs)
+	}
+
+	args, err := opts.args()
+	if err != nil {
+		return 0, err
+	}
+	if len(members) == 0 {
+		return 0, nil
+	}
+	for member, score := range members {
+		args = append(args, score, member)
+	}
+	var n int64
+	err = Do(&n, tag, "ZADD", key, args...)
+	return n, err
+}
+
+// ZAddIncr runs ZADD INCR, adding incr to the score of member, and returns
+// the new score. false means NX, XX, GT or LT prevented the update.
+func ZAddIncr(tag, key string, opts ZAddOptions, member string, incr float64) (float64, bool, error) {
+	opts.INCR = true
+	args, err := opts.args()
+	if err != nil {
+		return 0, false, err
+	}
+	args = append(args, incr, member)
+
+	var score float64
+	ok, err := doMaybe(&score, tag, "ZADD", key, args...)
+	return score, ok, err
+}
+
+// ScoreBound is a min or max of ZRangeByScore, math.Inf(-1) and math.Inf(1)
+// give -inf and +inf.
+type ScoreBound struct {
+	Value     float64
+	Exclusive bool
+}
+
+func (b ScoreBound) String() string {
+	var v string
+	switch {
+	case math.IsInf(b.Value, -1):
+		v = "-inf"
+	case math.IsInf(b.Value, 1):
+		v = "+inf"
+	default:
+		v = strconv.FormatFloat(b.Value, 'f', -1, 64)
+	}
+	if b.Exclusive {
+		return "(" + v
+	}
+	return v
+}
+
+// LexBound is a min or max of ZRangeByLex, Unbounded stands for - as min and
+// + as max.
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	Unbounded bool
+}
+
+func (b LexBound) format(min bool) string {
+	if b.Unbounded {
+		if min {
+			return "-"
+		}
+		return "+"
+	}
+	if b.Exclusive {
+		return "(" + b.Value
+	}
+	return "[" + b.Value
+}
+
+// limitArgs returns LIMIT offset count, count <= 0 meaning all the rest.
+func limitArgs(offset, count int) []string {
+	if offset <= 0 && count <= 0 {
+		return nil
+	}
+	if count <= 0 {
+		count = -1
+	}
+	return []string{"LIMIT", strconv.Itoa(offset), strconv.Itoa(count)}
+}
+
+// ZRangeByScore returns the members with scores between min and max, lowest
+// first, with their scores. offset and count page through the result.
+func ZRangeByScore(tag, key string, min, max ScoreBound, offset, count int) ([]ZMember, error) {
+	args := append([]string{key, min.String(), max.String(), "WITHSCORES"}, limitArgs(offset, count)...)
+	var reply []string
+	if err := DoCmd(&reply, tag, "ZRANGEBYSCORE", args...); err != nil {
+		return nil, err
+	}
+	return parseZMemberFlat("ZRANGEBYSCORE", reply)
+}
+
+// ZRangeByLex returns the members between min and max in lexicographical
+// order, meant for sets whose members all have the same score.
+func ZRangeByLex(tag, key string, min, max LexBound, offset, count int) ([]string, error) {
+	args := append([]string{key, min.format(true), max.format(false)}, limitArgs(offset, count)...)
+	var ret []string
+	if err := DoCmd(&ret, tag, "ZRANGEBYLEX", args...); err != nil {
+		return nil, err
+	}
+	if ret == nil {
+		ret = []string{}
+	}
+	return ret, nil
+}
+
+// parseZMemberFlat parses [member, score, member, score, ...] replies.
+func parseZMemberFlat(cmd string, reply []string) ([]ZMember, error) {
+	if len(reply)%2 != 0 {
+		return nil, fmt.Errorf("Unexpected %s reply length %d", cmd, len(reply))
+	}
+	members := make([]ZMember, 0, len(reply)/2)
+	for i := 0; i < len(reply); i += 2 {
+		score, err := strconv.ParseFloat(reply[i+1], 64)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, ZMember{Member: reply[i], Score: score})
+	}
+	return members, nil
+}
+
+// ZPopMin removes and returns up to count members with the lowest scores.
+func ZPopMin(tag, key string, count int) ([]ZMember, error) {
+	return zPop(tag, "ZPOPMIN", key, count)
+}
+
+// ZPopMax removes and returns up to count members with the highest scores.
+func ZPopMax(tag, key string, count int) ([]ZMember, error) {
+	return zPop(tag, "ZPOPMAX", key, count)
+}
+
+func zPop(tag, cmd, key string, count int) ([]ZMember, error) {
+	if count <= 0 {
+		count = 1
+	}
+	var reply []string
+	if err := Do(&reply, tag, cmd, key, count); err != nil {
+		return nil, err
+	}
+	return parseZMemberFlat(cmd, reply)
+}
+
+// BZPopMin pops the lowest member of the first non-empty sorted set of keys,
+// waiting up to timeout (0 waits forever) for one to arrive. An empty key
+// means the timeout passed. The command runs on a dedicated connection whose
+// read timeout covers the wait, so it doesn't hold a pooled connection.
+func BZPopMin(tag string, timeout time.Duration, keys ...string) (key string, m ZMember, err error) {
+	t := time.Now()
+	defer func() {
+		logInfo("redis.BZPopMin cost:%v tag:%s keys:%v key:%s err:%v", time.Since(t), tag, keys, key, err)
+	}()
+
+	if len(keys) == 0 {
+		return "", ZMember{}, fmt.Errorf("%w: BZPOPMIN needs at least one key", ErrBadArgs)
+	}
+	if err = checkSameSlot(tag, keys...); err != nil {
+		return "", ZMember{}, err
+	}
+	if err = checkCommand(tag, "BZPOPMIN"); err != nil {
+		return "", ZMember{}, err
+	}
+
+	var readTimeout time.Duration
+	if timeout > 0 {
+		readTimeout = timeout + getOptionByTag(tag).timeout
+	}
+	conn, err := dialNode(tag, keys[0], readTimeout)
+	if err != nil {
+		return "", ZMember{}, err
+	}
+	defer conn.Close()
+
+	args := append(append([]string{}, keys...), strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64))
+	var reply []string
+	mn := radix.MaybeNil{Rcv: &reply}
+	if err = conn.Do(radix.Cmd(&mn, "BZPOPMIN", args...)); err != nil {
+		return "", ZMember{}, wrapErr(tag, "BZPOPMIN", err)
+	}
+	if mn.Nil {
+		return "", ZMember{}, nil
+	}
+	if len(reply) != 3 {
+		return "", ZMember{}, fmt.Errorf("Unexpected BZPOPMIN reply of %d elements", len(reply))
+	}
+	score, err := strconv.ParseFloat(reply[2], 64)
+	if err != nil {
+		return "", ZMember{}, err
+	}
+	return reply[0], ZMember{Member: reply[1], Score: score}, nil
+}
+
+// ZUnion returns the union of the sorted sets at keys (Redis 6.2) without
+// storing it. weights, if given, multiply the scores of each key. Without
+// withScores the Score of every member is 0. On cluster keys must share a
+// slot.
+func ZUnion(tag string, keys []string, weights []float64, withScores bool) ([]ZMember, error) {
+	return zSetOp(tag, "ZUNION", keys, weights, withScores)
+}
+
+// ZInter is ZUnion for the intersection.
+func ZInter(tag string, keys []string, weights []float64, withScores bool) ([]ZMember, error) {
+	return zSetOp(tag, "ZINTER", keys, weights, withScores)
+}
+
+// ZDiff returns the members of the first key not in any of the others.
+func ZDiff(tag string, keys []string, withScores bool) ([]ZMember, error) {
+	return zSetOp(tag, "ZDIFF", keys, nil, withScores)
+}
+
+func zSetOp(tag, cmd string, keys []string, weights []float64, withScores bool) ([]ZMember, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: %s needs at least one key", ErrBadArgs, cmd)
+	}
+	if len(weights) > 0 && len(weights) != len(keys) {
+		return nil, fmt.Errorf("%w: %d weights for %d keys", ErrBadArgs, len(weights), len(keys))
+	}
+	if err := checkSameSlot(tag, keys...); err != nil {
+		return nil, err
+	}
+	if err := requireVersion(tag, "6.2.0"); err != nil {
+		return nil, err
+	}
+
+	args := append([]string{strconv.Itoa(len(keys))}, keys...)
+	if len(weights) > 0 {
+		args = append(args, "WEIGHTS")
+		for _, w := range weights {
+			args = append(args, strconv.FormatFloat(w, 'f', -1, 64))
+		}
+	}
+	if withScores {
+		args = append(args, "WITHSCORES")
+	}
+
+	var reply []string
+	if err := DoCmd(&reply, tag, cmd, args...); err != nil {
+		return nil, err
+	}
+	if withScores {
+		return parseZMemberFlat(cmd, reply)
+	}
+	members := make([]ZMember, len(reply))
+	for i, m := range reply {
+		members[i].Member = m
+	}
+	return members, nil
+}